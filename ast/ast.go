@@ -33,10 +33,20 @@ const (
 	LET
 	RETURN
 	WHILE
+	FOR
+	FOR_IN
+	BREAK
+	CONTINUE
 	ARRAY
 	HASH
 	INDEX
+	SLICE
 	CALL
+	ASSIGN
+	COMPOUND_ASSIGN
+	CONDITIONAL
+	COALESCE
+	OPTIONAL_CHAIN
 )
 
 type Node interface {
@@ -93,14 +103,19 @@ func (b *BlockStmtNode) String() string {
 
 // Sentencias
 type LetStmtNode struct {
-	Name  IdentifierNode
-	Value Expression
+	Name     IdentifierNode
+	Value    Expression
+	Constant bool // declarado con `const`: no admite asignación ni redefinición
 }
 
 func (ls *LetStmtNode) statementNode() {}
 func (ls *LetStmtNode) Type() Type     { return LET }
 func (ls *LetStmtNode) String() string {
-	return fmt.Sprintf("let %s = %s", ls.Name.String(), ls.Value.String())
+	keyword := "let"
+	if ls.Constant {
+		keyword = "const"
+	}
+	return fmt.Sprintf("%s %s = %s", keyword, ls.Name.String(), ls.Value.String())
 }
 
 type ReturnStmtNode struct {
@@ -136,6 +151,80 @@ func (ws *WhileStmtNode) String() string {
 	return out.String()
 }
 
+// for (init; condition; step) { ... }. Cualquiera de las tres partes
+// puede omitirse; sin condición el ciclo solo termina con break o return.
+type ForStmtNode struct {
+	Init      Statement // LetStmtNode, ExpressionStmtNode o nil
+	Condition Expression
+	Step      Expression
+	Body      *BlockStmtNode
+}
+
+func (fs *ForStmtNode) statementNode() {}
+func (fs *ForStmtNode) Type() Type     { return FOR }
+func (fs *ForStmtNode) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	if fs.Init != nil {
+		out.WriteString(fs.Init.String())
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Step != nil {
+		out.WriteString(fs.Step.String())
+	}
+	out.WriteString(")")
+
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// for (value in iterable) { ... } o for (key, value in iterable) { ... }
+type ForInStmtNode struct {
+	Key      *IdentifierNode // nil si solo se pide el valor
+	Value    IdentifierNode
+	Iterable Expression
+	Body     *BlockStmtNode
+}
+
+func (fs *ForInStmtNode) statementNode() {}
+func (fs *ForInStmtNode) Type() Type     { return FOR_IN }
+func (fs *ForInStmtNode) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(")")
+
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// break y continue solo son válidos dentro de un ciclo
+type BreakStmtNode struct{}
+
+func (bs *BreakStmtNode) statementNode() {}
+func (bs *BreakStmtNode) Type() Type     { return BREAK }
+func (bs *BreakStmtNode) String() string { return "break" }
+
+type ContinueStmtNode struct{}
+
+func (cs *ContinueStmtNode) statementNode() {}
+func (cs *ContinueStmtNode) Type() Type     { return CONTINUE }
+func (cs *ContinueStmtNode) String() string { return "continue" }
+
 type ExpressionStmtNode struct {
 	Expression Expression
 }
@@ -192,9 +281,12 @@ func (cn *CallExprNode) String() string {
 	return out.String()
 }
 
+// Optional indica `callee?[index]` o `callee?.name`: si callee es null
+// el resto de la cadena no se evalúa, ver OptionalChainNode
 type IndexExprNode struct {
-	Callee Expression
-	Index  Expression
+	Callee   Expression
+	Index    Expression
+	Optional bool
 }
 
 func (cn *IndexExprNode) expressionNode() {}
@@ -202,6 +294,9 @@ func (cn *IndexExprNode) Type() Type      { return INDEX }
 func (cn *IndexExprNode) String() string {
 	var out bytes.Buffer
 	out.WriteString(cn.Callee.String())
+	if cn.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	if cn.Index != nil {
 		out.WriteString(cn.Index.String())
@@ -210,6 +305,100 @@ func (cn *IndexExprNode) String() string {
 	return out.String()
 }
 
+// callee[start:end], Start y End son nil si se omiten. Optional igual que
+// en IndexExprNode.
+type SliceExprNode struct {
+	Callee   Expression
+	Start    Expression
+	End      Expression
+	Optional bool
+}
+
+func (sn *SliceExprNode) expressionNode() {}
+func (sn *SliceExprNode) Type() Type      { return SLICE }
+func (sn *SliceExprNode) String() string {
+	var out bytes.Buffer
+	out.WriteString(sn.Callee.String())
+	if sn.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if sn.Start != nil {
+		out.WriteString(sn.Start.String())
+	}
+	out.WriteString(":")
+	if sn.End != nil {
+		out.WriteString(sn.End.String())
+	}
+	out.WriteString("]")
+	return out.String()
+}
+
+// target = value, por ahora el destino solo puede ser un IndexExprNode
+type AssignExprNode struct {
+	Target Expression
+	Value  Expression
+}
+
+func (an *AssignExprNode) expressionNode() {}
+func (an *AssignExprNode) Type() Type      { return ASSIGN }
+func (an *AssignExprNode) String() string {
+	return fmt.Sprintf("(%s = %s)", an.Target, an.Value)
+}
+
+// target op= value. `x++` y `x--` usan Value 1 y Postfix, y su resultado
+// es el valor anterior. Op es el operador binario: `+` para `+=` y `++`.
+type CompoundAssignExprNode struct {
+	Target  Expression
+	Op      token.Token
+	Value   Expression
+	Postfix bool
+}
+
+func (cn *CompoundAssignExprNode) expressionNode() {}
+func (cn *CompoundAssignExprNode) Type() Type      { return COMPOUND_ASSIGN }
+func (cn *CompoundAssignExprNode) String() string {
+	if cn.Postfix {
+		return fmt.Sprintf("(%s%s%s)", cn.Target, cn.Op.Literal, cn.Op.Literal)
+	}
+	return fmt.Sprintf("(%s %s= %s)", cn.Target, cn.Op.Literal, cn.Value)
+}
+
+// condition ? consequence : alternative
+type ConditionalExprNode struct {
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (cn *ConditionalExprNode) expressionNode() {}
+func (cn *ConditionalExprNode) Type() Type      { return CONDITIONAL }
+func (cn *ConditionalExprNode) String() string {
+	return fmt.Sprintf("(%s ? %s : %s)", cn.Condition, cn.Consequence, cn.Alternative)
+}
+
+// left ?? right, right solo se evalúa si left es null
+type CoalesceExprNode struct {
+	Left  Expression
+	Right Expression
+}
+
+func (cn *CoalesceExprNode) expressionNode() {}
+func (cn *CoalesceExprNode) Type() Type      { return COALESCE }
+func (cn *CoalesceExprNode) String() string {
+	return fmt.Sprintf("(%s ?? %s)", cn.Left, cn.Right)
+}
+
+// Una cadena de llamadas e índices con al menos un `?.` o `?[`. Si el
+// callee de uno de ellos es null la cadena completa vale null.
+type OptionalChainNode struct {
+	Chain Expression
+}
+
+func (on *OptionalChainNode) expressionNode() {}
+func (on *OptionalChainNode) Type() Type      { return OPTIONAL_CHAIN }
+func (on *OptionalChainNode) String() string  { return on.Chain.String() }
+
 // controladores de flujo
 type IfExprNode struct {
 	Condition   Expression
@@ -311,6 +500,7 @@ func (hn *HashLiteralNode) String() string {
 // tipos nativos
 type IdentifierNode struct {
 	Value string
+	Token token.Token // para ubicar los errores en el código
 }
 
 func (id *IdentifierNode) expressionNode() {}
@@ -17,6 +17,14 @@ const (
 	OpSub
 	OpMul
 	OpDiv
+	OpMod      // residuo con el signo del divisor
+	OpPow      // potencia entera
+	OpFloorDiv // división redondeando hacia menos infinito
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
 	OpTrue
 	OpFalse
 	OpNull
@@ -28,10 +36,13 @@ const (
 	OpNotEq
 	OpNegInt
 	OpNegBool
+	OpBitNot
 	OpAnd
 	OpOr
 	OpJumpNotTrue
 	OpJump
+	OpJumpNull    // salta si el tope es null, sin sacarlo
+	OpJumpNotNull // salta si el tope no es null; si lo es, lo saca
 	OpSetGlobal
 	OpGetGlobal
 	OpSetLocal
@@ -39,12 +50,24 @@ const (
 	OpArray
 	OpHash
 	OpAccess
+	OpAccessKeep // como OpAccess pero deja la colección y el índice en la pila
+	OpSlice
+	OpSetIndex
+	OpIterator // convierte la colección en un iterador
+	OpIterNext // avanza el iterador o salta al final del ciclo
 	OpCall
 	OpReturnValue // retorna el objeto de la pila
 	OpReturn      // retorna desde la función actual
 	OpGetBuiltin
 	OpClosure
 	OpGetFree
+	OpSetFree
+	OpCaptureFree // la celda de una variable libre, para pasarla a otra closure
+	OpNewCell     // guarda el tope en una celda nueva en la variable local
+	OpGetCell     // el valor de la celda de una variable local
+	OpSetCell     // cambia el valor de la celda de una variable local
+	// la closure que se está ejecutando, para que una función se llame a sí misma
+	OpCurClosure
 	OpPop // le indica a la vm que limpie la pila
 )
 
@@ -56,6 +79,14 @@ var nemmonics = map[OpCode]string{
 	OpSub:         "SUB",
 	OpMul:         "MUL",
 	OpDiv:         "DIV",
+	OpMod:         "MOD",
+	OpPow:         "POW",
+	OpFloorDiv:    "FLOOR_DIV",
+	OpBitAnd:      "BIT_AND",
+	OpBitOr:       "BIT_OR",
+	OpBitXor:      "BIT_XOR",
+	OpShiftLeft:   "SHIFT_LEFT",
+	OpShiftRight:  "SHIFT_RIGHT",
 	OpTrue:        "PUSH true",
 	OpFalse:       "PUSH false",
 	OpNull:        "PUSH null",
@@ -67,10 +98,13 @@ var nemmonics = map[OpCode]string{
 	OpNotEq:       "NOT_EQ",
 	OpNegInt:      "NEG_INT",
 	OpNegBool:     "NEG_BOOL",
+	OpBitNot:      "BIT_NOT",
 	OpAnd:         "AND",
 	OpOr:          "OR",
 	OpJumpNotTrue: "JUMP_NOT_TRUE",
 	OpJump:        "JUMP",
+	OpJumpNull:    "JUMP_NULL",
+	OpJumpNotNull: "JUMP_NOT_NULL",
 	OpSetGlobal:   "SET GLOBAL",
 	OpGetGlobal:   "GET GLOBAL",
 	OpSetLocal:    "SET LOCAL",
@@ -78,12 +112,23 @@ var nemmonics = map[OpCode]string{
 	OpArray:       "ARRAY OF",
 	OpHash:        "HASH OF",
 	OpAccess:      "ACCESS",
+	OpAccessKeep:  "ACCESS_KEEP",
+	OpSlice:       "SLICE",
+	OpSetIndex:    "SET INDEX",
+	OpIterator:    "ITERATOR",
+	OpIterNext:    "ITER_NEXT",
 	OpCall:        "CALL",
 	OpReturnValue: "RETURN_VALUE",
 	OpReturn:      "RETURN",
 	OpGetBuiltin:  "GET BUILTIN",
 	OpClosure:     "CLOSURE",
 	OpGetFree:     "GET FREE",
+	OpSetFree:     "SET FREE",
+	OpCaptureFree: "CAPTURE FREE",
+	OpNewCell:     "NEW CELL",
+	OpGetCell:     "GET CELL",
+	OpSetCell:     "SET CELL",
+	OpCurClosure:  "CURRENT CLOSURE",
 	OpPop:         "POP",
 }
 
@@ -13,12 +13,74 @@ import (
 type ByteCode struct {
 	Instructions []code.Instruction
 	ObjectPool   []object.Object
+	NumLocals    int // locales del frame principal, para los bloques del nivel superior
 }
 
 // Para gestionar los ámbitos de compilación
 type CompiledFrame struct {
 	instructions []code.Instruction
-	ic           int //contador de instrucciones
+	ic           int           //contador de instrucciones
+	depth        int           // valores que el código emitido deja en la pila
+	loops        []loopContext // ciclos abiertos, el último es el más interno
+	chains       [][]int       // saltos de los `?.` y `?[` de cada cadena opcional abierta
+	// accesos a las variables locales y variables capturadas por alguna
+	// closure. Al terminar el frame, los accesos a una variable capturada
+	// pasan a usar su celda.
+	localAccesses []localAccess
+	captured      map[localVariable]bool
+}
+
+// Una variable local se identifica por la tabla que la define y su
+// índice: un bloque posterior puede reutilizar el índice para otra.
+type localVariable struct {
+	table *SymbolTable
+	index int
+}
+
+// instrucción que lee o escribe una variable local
+type localAccess struct {
+	variable localVariable
+	position int
+	// el let o el for-in que crea la variable
+	definition bool
+}
+
+// Saltos pendientes de un ciclo. Los break y continue se emiten antes de
+// conocer su destino, así que guardamos sus posiciones para actualizarlas
+// al terminar de compilar el ciclo.
+type loopContext struct {
+	breaks    []int
+	continues []int
+	// altura de la pila en el destino de cada salto. Un break o continue
+	// dentro de una expresión, como `[1, if (c) { break }]`, saca antes los
+	// valores que la expresión ya apiló; el de un for-in saca también el
+	// iterador.
+	breakDepth    int
+	continueDepth int
+}
+
+// instrucción de cada operador binario
+var binaryOperators = map[token.Type]code.OpCode{
+	token.PLUS:        code.OpAdd,
+	token.MINUS:       code.OpSub,
+	token.ASTERISK:    code.OpMul,
+	token.SLASH:       code.OpDiv,
+	token.PERCENT:     code.OpMod,
+	token.POWER:       code.OpPow,
+	token.FLOORDIV:    code.OpFloorDiv,
+	token.BIT_AND:     code.OpBitAnd,
+	token.BIT_OR:      code.OpBitOr,
+	token.BIT_XOR:     code.OpBitXor,
+	token.SHIFT_LEFT:  code.OpShiftLeft,
+	token.SHIFT_RIGHT: code.OpShiftRight,
+	token.LT:          code.OpLess,
+	token.LT_EQ:       code.OpLessEq,
+	token.GT:          code.OpGreater,
+	token.GT_EQ:       code.OpGreaterEq,
+	token.EQ:          code.OpEqual,
+	token.NOT_EQ:      code.OpNotEq,
+	token.AND:         code.OpAnd,
+	token.OR:          code.OpOr,
 }
 
 // Compiler se encarga de recorrer el AST y emitir el bytecode
@@ -30,22 +92,33 @@ type Compiler struct {
 	curFrame    *CompiledFrame
 }
 
-// Creamos una instancia del compilador
+// Creamos una instancia del compilador con los builtins estándar
 func New() *Compiler {
-	mainFrame := CompiledFrame{
-		instructions: []code.Instruction{},
-		ic:           0,
-	}
+	return NewWithBuiltins(object.NewBuiltinRegistry())
+}
+
+// Crea un compilador que resuelve los builtins del registro indicado.
+// La vm que ejecute el bytecode debe usar el mismo registro.
+func NewWithBuiltins(builtins *object.BuiltinRegistry) *Compiler {
 	// creamos la tabla de símbolos
 	symbolTable := NewSymbolTable()
 	// definimos los built-ins
-	for i, builtin := range object.Builtins {
-		symbolTable.DefineBuiltin(i, builtin.Name)
+	symbolTable.DefineBuiltins(builtins)
+
+	return NewWithState(symbolTable, []object.Object{})
+}
+
+// Crea un nuevo Compiler pero con SymbolTable. La tabla ya debe tener
+// definidos los builtins del registro que use la vm.
+func NewWithState(s *SymbolTable, objectPool []object.Object) *Compiler {
+	mainFrame := CompiledFrame{
+		instructions: []code.Instruction{},
+		ic:           0,
 	}
 
 	comp := &Compiler{
-		objectPool:  []object.Object{},
-		symbolTable: symbolTable,
+		objectPool:  objectPool,
+		symbolTable: s,
 		frames:      []CompiledFrame{mainFrame},
 		frameIndex:  0,
 	}
@@ -53,29 +126,129 @@ func New() *Compiler {
 	return comp
 }
 
-// Crea un nuevo Compiler pero con SymbolTable
-func NewWithState(s *SymbolTable, objectPool []object.Object) *Compiler {
-	compiler := New()
-	compiler.symbolTable = s
-	compiler.objectPool = objectPool
-
-	return compiler
-}
-
 // setSymbol
 func (c *Compiler) setSymbol(symbol Symbol) {
+	if symbol.HasLiteral {
+		c.addInstruction(code.OpConstant, symbol.Literal, symbol.Name, 0)
+		return
+	}
 	switch symbol.Scope {
 	case GlobalScope:
 		c.addInstruction(code.OpGetGlobal, symbol.Index, symbol.Name, 0)
 	case LocalScope:
-		c.addInstruction(code.OpGetLocal, symbol.Index, symbol.Name, 0)
+		position := c.addInstruction(code.OpGetLocal, symbol.Index, symbol.Name, 0)
+		c.accessLocal(symbol, position, false)
 	case BuiltinScope:
 		c.addInstruction(code.OpGetBuiltin, symbol.Index, symbol.Name, 0)
 	case FreeScope:
 		c.addInstruction(code.OpGetFree, symbol.Index, symbol.Name, 0)
+	case FunctionScope:
+		c.addInstruction(code.OpCurClosure, 0, symbol.Name, 0)
+	}
+}
+
+// emite la instrucción que guarda el tope de la pila en el símbolo
+func (c *Compiler) storeSymbol(symbol Symbol) {
+	c.store(symbol, false)
+}
+
+// como storeSymbol, para el valor inicial de una variable recién definida
+func (c *Compiler) defineSymbol(symbol Symbol) {
+	c.store(symbol, true)
+}
+
+func (c *Compiler) store(symbol Symbol, definition bool) {
+	switch symbol.Scope {
+	case LocalScope:
+		position := c.addInstruction(code.OpSetLocal, symbol.Index, symbol.Name, 0)
+		c.accessLocal(symbol, position, definition)
+	case FreeScope:
+		// cambia el valor de la celda compartida con la función exterior
+		c.addInstruction(code.OpSetFree, symbol.Index, symbol.Name, 0)
+	default:
+		c.addInstruction(code.OpSetGlobal, symbol.Index, symbol.Name, 0)
 	}
 }
 
+// registra el acceso a una variable local del frame actual
+func (c *Compiler) accessLocal(symbol Symbol, position int, definition bool) {
+	variable := localVariable{table: c.symbolTable.definition(symbol.Name), index: symbol.Index}
+	access := localAccess{variable: variable, position: position, definition: definition}
+	c.curFrame.localAccesses = append(c.curFrame.localAccesses, access)
+}
+
+// Carga una variable libre de una función que se está creando. Las
+// variables locales y libres se pasan como celdas para que la función
+// y la closure compartan su valor.
+func (c *Compiler) captureSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case LocalScope:
+		variable := localVariable{table: c.symbolTable.definition(symbol.Name), index: symbol.Index}
+		if c.curFrame.captured == nil {
+			c.curFrame.captured = make(map[localVariable]bool)
+		}
+		c.curFrame.captured[variable] = true
+		// la variable ya guarda su celda: se carga tal cual
+		c.addInstruction(code.OpGetLocal, symbol.Index, symbol.Name, 0)
+	case FreeScope:
+		c.addInstruction(code.OpCaptureFree, symbol.Index, symbol.Name, 0)
+	default:
+		c.setSymbol(symbol)
+	}
+}
+
+// Cambia los accesos a las variables capturadas del frame actual por
+// accesos a su celda y devuelve los parámetros capturados, que la vm
+// guarda en una celda al llamar a la función.
+func (c *Compiler) useCells(parameters int) []int {
+	for _, access := range c.curFrame.localAccesses {
+		if !c.curFrame.captured[access.variable] {
+			continue
+		}
+		instruction := &c.curFrame.instructions[access.position]
+		switch {
+		case instruction.OpCode == code.OpGetLocal:
+			instruction.OpCode = code.OpGetCell
+		case instruction.OpCode == code.OpSetLocal && access.definition:
+			instruction.OpCode = code.OpNewCell
+		case instruction.OpCode == code.OpSetLocal:
+			instruction.OpCode = code.OpSetCell
+		}
+	}
+
+	cells := []int{}
+	for index := 0; index < parameters; index++ {
+		if c.curFrame.captured[localVariable{table: c.symbolTable, index: index}] {
+			cells = append(cells, index)
+		}
+	}
+	return cells
+}
+
+// resuelve el destino de una asignación
+func (c *Compiler) assignableSymbol(target *ast.IdentifierNode) (Symbol, error) {
+	symbol, ok := c.symbolTable.Resolve(target.Value)
+	if !ok {
+		return symbol, fmt.Errorf("undefined variable %s", target.Value)
+	}
+	if symbol.Constant {
+		return symbol, positionedError(target.Token, "cannot assign to constant %s", target.Value)
+	}
+	switch symbol.Scope {
+	case BuiltinScope:
+		return symbol, fmt.Errorf("cannot assign to builtin %s", target.Value)
+	case FunctionScope:
+		// el nombre de la propia función es la closure misma
+		return symbol, fmt.Errorf("cannot assign to function %s", target.Value)
+	case FreeScope:
+		// tampoco el de una función exterior capturado por una closure
+		if c.symbolTable.freeOrigin(symbol).Scope == FunctionScope {
+			return symbol, fmt.Errorf("cannot assign to function %s", target.Value)
+		}
+	}
+	return symbol, nil
+}
+
 // Crea un nuevo ámbito de instrucciones
 func (c *Compiler) loadFrame() {
 	newFrame := CompiledFrame{
@@ -88,8 +261,9 @@ func (c *Compiler) loadFrame() {
 	// Emparentar la tabla de símbolos actual
 	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
 
-	// actualizamos el currentFrame
-	c.curFrame = &newFrame
+	// actualizamos el currentFrame apuntando al frame guardado en el array
+	// (no a la copia local) para no perder lo emitido al anidar funciones.
+	c.curFrame = &c.frames[c.frameIndex]
 
 	//parentSymbolTable := c.symbolTable
 	//childSymbolTable := NewEnclosedSymbolTable(parentSymbolTable)
@@ -147,19 +321,30 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.addInstruction(code.OpPop, 0, "", 0)
 
 	case *ast.LetStmtNode:
+		if c.symbolTable.IsConstant(node.Name.Value) {
+			return positionedError(node.Name.Token, "cannot redefine constant %s", node.Name.Value)
+		}
 		// el chiste es generar un símbolo con un índice único.
-		opCode := code.OpSetGlobal
-		symbol := c.symbolTable.Define(node.Name.Value)
-
-		err := c.Compile(node.Value)
+		// El valor se compila antes de definir el nombre para que
+		// `let x = x + 1` lea la x exterior y no el nuevo símbolo vacío.
+		// Una función se llama a sí misma con OpCurClosure.
+		var err error
+		if function, ok := node.Value.(*ast.FunLiteralNode); ok {
+			err = c.compileFunction(function, node.Name.Value)
+		} else {
+			err = c.Compile(node.Value)
+		}
 		if err != nil {
 			return err
 		}
 
-		if symbol.Scope == LocalScope {
-			opCode = code.OpSetLocal
+		var symbol Symbol
+		if node.Constant {
+			symbol = c.symbolTable.DefineConstant(node.Name.Value, c.literalIndex(node.Value))
+		} else {
+			symbol = c.symbolTable.Define(node.Name.Value)
 		}
-		c.addInstruction(opCode, symbol.Index, node.Name.Value, 0)
+		c.defineSymbol(symbol)
 
 	case *ast.IdentifierNode:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
@@ -183,34 +368,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 		// Emitimos la instrucción según el tipo de operador binario.
-		switch node.Op.Type {
-		case token.PLUS:
-			c.addInstruction(code.OpAdd, 0, "", 0)
-		case token.MINUS:
-			c.addInstruction(code.OpSub, 0, "", 0)
-		case token.ASTERISK:
-			c.addInstruction(code.OpMul, 0, "", 0)
-		case token.SLASH:
-			c.addInstruction(code.OpDiv, 0, "", 0)
-		case token.LT:
-			c.addInstruction(code.OpLess, 0, "", 0)
-		case token.LT_EQ:
-			c.addInstruction(code.OpLessEq, 0, "", 0)
-		case token.GT:
-			c.addInstruction(code.OpGreater, 0, "", 0)
-		case token.GT_EQ:
-			c.addInstruction(code.OpGreaterEq, 0, "", 0)
-		case token.EQ:
-			c.addInstruction(code.OpEqual, 0, "", 0)
-		case token.NOT_EQ:
-			c.addInstruction(code.OpNotEq, 0, "", 0)
-		case token.AND:
-			c.addInstruction(code.OpAnd, 0, "", 0)
-		case token.OR:
-			c.addInstruction(code.OpOr, 0, "", 0)
-		default:
+		opCode, ok := binaryOperators[node.Op.Type]
+		if !ok {
 			return fmt.Errorf("unknown operator %s", node.Op.Literal)
 		}
+		c.addInstruction(opCode, 0, "", 0)
 
 	case *ast.Unary:
 		err := c.Compile(node.Right)
@@ -222,6 +384,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.addInstruction(code.OpNegInt, 0, "", 0)
 		case token.BANG:
 			c.addInstruction(code.OpNegBool, 0, "", 0)
+		case token.BIT_NOT:
+			c.addInstruction(code.OpBitNot, 0, "", 0)
 		default:
 			return fmt.Errorf("unknown operator for unary expression %s", node.Op.Literal)
 		}
@@ -250,55 +414,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.addInstruction(code.OpNull, 0, "", 0)
 
 	case *ast.FunLiteralNode:
-		// entramos en un nuevo ámbito de instrucciones para la función
-		c.loadFrame()
-
-		// compilar los parámetros y tratarlos como local bindings
-		for _, parameter := range node.Parameters {
-			c.symbolTable.Define(parameter.Value)
-		}
-		err := c.Compile(node.Body)
-		if err != nil {
-			return err
-		}
-
-		// Revisamos si la última instrucción emitida es un OpPop
-		// para cambiarla por un Return y así evitar que la
-		// máquina virtual se la cargue.
-		if c.lastInstructionIs(code.OpPop) {
-			lastIndex := len(c.curFrame.instructions) - 1
-			c.curFrame.instructions[lastIndex].OpCode = code.OpReturnValue
-		}
-		// Si no hay ni una expresión suelta (controlada arriba) ni un OpReturnValue
-		// entonces es una funcion que no retorna nada y eso es malo, para arreglarlo
-		// creamos a huevo una instrucción OpReturn que retorna null (la vm lo hará.)
-		if !c.lastInstructionIs(code.OpReturnValue) {
-			c.addInstruction(code.OpReturn, 0, "null", 0)
-		}
-		// número de variables libres
-		freeSymbols := c.symbolTable.FreeSymbols
-		// Calculamos el número de variables creadas
-		// recordemos que al entrar en un nuevo symbolTable
-		// el número de definiciones empieza en cero.
-		numLocals := c.symbolTable.numDefinitions
-
-		// dejamos el ámbito y lo guardamos para la función
-		functionFrame := c.unloadFrame()
-
-		for _, s := range freeSymbols {
-			c.setSymbol(s)
-		}
-
-		// creamos el objeto compiledFunction
-		functionObj := &object.CompiledFunction{
-			Instructions:  functionFrame.instructions,
-			NumLocals:     numLocals,
-			NumParameters: len(node.Parameters),
-			StrByteCode:   c.PrintInstructions(functionFrame.instructions),
-		}
-		index := c.addConstant(functionObj)
-		//c.addInstruction(code.OpConstant, index, "FUNCTION", 0)
-		c.addInstruction(code.OpClosure, index, "CLOSURE", len(freeSymbols))
+		return c.compileFunction(node, "")
 
 	case *ast.CallExprNode:
 		err := c.Compile(node.Callee)
@@ -317,16 +433,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.addInstruction(code.OpCall, len(node.Arguments), "", 0)
 
 	case *ast.ArrayLiteralNode:
-		// compilamos los elementos del array en modo inverso
-		// para que la máquina virtual los agregue en el orden correcto.
-		// recuerda que la pila trabaja en modo LIFO
-		size := len(node.Elements) - 1
-		for i := size; i >= 0; i-- {
-			c.Compile(node.Elements[i])
+		// compilamos los elementos en orden, la vm los toma
+		// de la pila en ese mismo orden.
+		for _, element := range node.Elements {
+			err := c.Compile(element)
+			if err != nil {
+				return err
+			}
 		}
 		// emitimos una instucción OpArray cuyo índice es el total de
 		// elementos que la vm deberá sacar de la pila.
-		c.addInstruction(code.OpArray, size, fmt.Sprintf("%d", size+1), 0)
+		size := len(node.Elements)
+		c.addInstruction(code.OpArray, size, fmt.Sprintf("%d", size), 0)
 
 	case *ast.HashLiteralNode:
 		// compilamos los elementos del diccionario.
@@ -337,15 +455,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 			// luego compilamos el key para que el primer
-			// pop() de la vm sea el value y el segundo pop() sea el key.
+			// pop() de la vm sea el key y el segundo pop() sea el value.
 			err = c.Compile(key)
 			if err != nil {
 				return err
 			}
 		}
-		// ahora emitimos la instrucción OpHash
-		size := len(node.Pairs) - 1
-		c.addInstruction(code.OpHash, size, fmt.Sprintf("%d", size+1), 0)
+		// ahora emitimos la instrucción OpHash con el número de pares
+		size := len(node.Pairs)
+		c.addInstruction(code.OpHash, size, fmt.Sprintf("%d", size), 0)
 
 	case *ast.IndexExprNode:
 
@@ -354,6 +472,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+		if node.Optional {
+			c.optionalJump()
+		}
 
 		// compilamos el índice (númerico o string)
 		err = c.Compile(node.Index)
@@ -363,6 +484,166 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.addInstruction(code.OpAccess, 0, "", 0)
 
+	case *ast.SliceExprNode:
+		err := c.Compile(node.Callee)
+		if err != nil {
+			return err
+		}
+		if node.Optional {
+			c.optionalJump()
+		}
+
+		// los extremos omitidos se envían como null
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.addInstruction(code.OpNull, 0, "null", 0)
+				continue
+			}
+			err := c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.addInstruction(code.OpSlice, 0, "", 0)
+
+	case *ast.AssignExprNode:
+		switch target := node.Target.(type) {
+		case *ast.IdentifierNode:
+			symbol, err := c.assignableSymbol(target)
+			if err != nil {
+				return err
+			}
+			err = c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			// la asignación es una expresión: guardamos el valor y lo
+			// volvemos a cargar para dejarlo en la pila.
+			c.storeSymbol(symbol)
+			c.setSymbol(symbol)
+
+		case *ast.IndexExprNode:
+			// la vm saca el valor, el índice y la colección en ese orden
+			for _, expression := range []ast.Expression{target.Callee, target.Index, node.Value} {
+				err := c.Compile(expression)
+				if err != nil {
+					return err
+				}
+			}
+			c.addInstruction(code.OpSetIndex, 0, "", 0)
+
+		default:
+			return fmt.Errorf("invalid assignment target: %s", node.Target)
+		}
+
+	case *ast.CompoundAssignExprNode:
+		opCode, ok := binaryOperators[node.Op.Type]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Op.Literal)
+		}
+		switch target := node.Target.(type) {
+		case *ast.IdentifierNode:
+			symbol, err := c.assignableSymbol(target)
+			if err != nil {
+				return err
+			}
+			c.setSymbol(symbol)
+			if node.Postfix {
+				// el valor anterior queda abajo como resultado
+				c.setSymbol(symbol)
+			}
+			err = c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			c.addInstruction(opCode, 0, "", 0)
+			c.storeSymbol(symbol)
+			if !node.Postfix {
+				c.setSymbol(symbol)
+			}
+
+		case *ast.IndexExprNode:
+			// la colección y el índice se evalúan una sola vez: OpAccessKeep
+			// los deja en la pila para el OpSetIndex
+			for _, expression := range []ast.Expression{target.Callee, target.Index} {
+				err := c.Compile(expression)
+				if err != nil {
+					return err
+				}
+			}
+			c.addInstruction(code.OpAccessKeep, 0, "", 0)
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+			c.addInstruction(opCode, 0, "", 0)
+			c.addInstruction(code.OpSetIndex, 0, "", 0)
+			if node.Postfix {
+				// OpSetIndex deja el valor nuevo; el anterior es el nuevo
+				// menos el 1 que se sumó (o más el que se restó)
+				err = c.Compile(node.Value)
+				if err != nil {
+					return err
+				}
+				if opCode == code.OpAdd {
+					c.addInstruction(code.OpSub, 0, "", 0)
+				} else {
+					c.addInstruction(code.OpAdd, 0, "", 0)
+				}
+			}
+
+		default:
+			return fmt.Errorf("invalid assignment target: %s", node.Target)
+		}
+
+	case *ast.OptionalChainNode:
+		c.curFrame.chains = append(c.curFrame.chains, []int{})
+		err := c.Compile(node.Chain)
+		if err != nil {
+			return err
+		}
+		// un callee null salta hasta aquí y queda como valor de la cadena
+		last := len(c.curFrame.chains) - 1
+		for _, jump := range c.curFrame.chains[last] {
+			c.updateOpCodePosition(jump, len(c.curFrame.instructions))
+		}
+		c.curFrame.chains = c.curFrame.chains[:last]
+
+	case *ast.CoalesceExprNode:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		jumpNotNullPos := c.addInstruction(code.OpJumpNotNull, 0, "", 0)
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+		c.updateOpCodePosition(jumpNotNullPos, len(c.curFrame.instructions))
+
+	case *ast.ConditionalExprNode:
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruePos := c.addInstruction(code.OpJumpNotTrue, 0, "", 0)
+		depth := c.curFrame.depth
+
+		err = c.Compile(node.Consequence)
+		if err != nil {
+			return err
+		}
+		jumpOpPos := c.addInstruction(code.OpJump, 0, "", 0)
+
+		c.updateOpCodePosition(jumpNotTruePos, len(c.curFrame.instructions))
+		c.curFrame.depth = depth
+		err = c.Compile(node.Alternative)
+		if err != nil {
+			return err
+		}
+		c.updateOpCodePosition(jumpOpPos, len(c.curFrame.instructions))
+
 	case *ast.IfExprNode:
 		err := c.Compile(node.Condition)
 		if err != nil {
@@ -371,16 +652,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// emitimos la instrucción OpJumpNotTrue con un valor falso
 		// que luego actualizaremos con el real.
 		jumpNotTruePos := c.addInstruction(code.OpJumpNotTrue, 0, "", 0)
+		depth := c.curFrame.depth
 
 		// ahora compilamos la consecuencia
-		err = c.Compile(node.Consequence)
+		err = c.compileBlock(node.Consequence)
 		if err != nil {
 			return err
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.keepBlockValue()
 
 		// emitimos el comando Jump para que salte
 		// una vez ejecutado el bloque del if.
@@ -389,18 +669,17 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// actualizamos la posición del OpJumpNotTrue
 		//c.updateOpCodePosition(jumpNotTruePos, len(c.getInstructions()))
 		c.updateOpCodePosition(jumpNotTruePos, len(c.curFrame.instructions))
+		// la alternativa empieza con la pila que dejó OpJumpNotTrue
+		c.curFrame.depth = depth
 
 		if node.Alternative != nil {
 			// ahora compilamos la alternativa
-			err = c.Compile(node.Alternative)
+			err = c.compileBlock(node.Alternative)
 			if err != nil {
 				return err
 			}
 			// revisamos si la última instrucción es un OpPop para eliminarlo porque nos dará morcilla luego.
-			//c.checkLastPop()
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.keepBlockValue()
 		} else {
 			// agregar un null por defecto
 			c.addInstruction(code.OpNull, 0, "", 0)
@@ -409,6 +688,131 @@ func (c *Compiler) Compile(node ast.Node) error {
 		//c.updateOpCodePosition(jumpOpPos, len(c.getInstructions()))
 		c.updateOpCodePosition(jumpOpPos, len(c.curFrame.instructions))
 
+	case *ast.WhileStmtNode:
+		loopStart := len(c.curFrame.instructions)
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruePos := c.addInstruction(code.OpJumpNotTrue, 0, "", 0)
+
+		c.enterLoop(c.curFrame.depth, c.curFrame.depth)
+		err = c.compileBlock(node.Body)
+		if err != nil {
+			return err
+		}
+		c.addInstruction(code.OpJump, loopStart, "", 0)
+
+		loopEnd := len(c.curFrame.instructions)
+		c.updateOpCodePosition(jumpNotTruePos, loopEnd)
+		c.leaveLoop(loopStart, loopEnd)
+		c.endLoop()
+
+	case *ast.ForStmtNode:
+		// las variables del init solo existen dentro del ciclo
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+		if node.Init != nil {
+			err := c.Compile(node.Init)
+			if err != nil {
+				return err
+			}
+		}
+
+		loopStart := len(c.curFrame.instructions)
+		jumpNotTruePos := -1
+		if node.Condition != nil {
+			err := c.Compile(node.Condition)
+			if err != nil {
+				return err
+			}
+			jumpNotTruePos = c.addInstruction(code.OpJumpNotTrue, 0, "", 0)
+		}
+
+		c.enterLoop(c.curFrame.depth, c.curFrame.depth)
+		err := c.compileBlock(node.Body)
+		if err != nil {
+			return err
+		}
+
+		// continue salta al step y no a la condición
+		stepStart := len(c.curFrame.instructions)
+		if node.Step != nil {
+			err := c.Compile(node.Step)
+			if err != nil {
+				return err
+			}
+			c.addInstruction(code.OpPop, 0, "", 0)
+		}
+		c.addInstruction(code.OpJump, loopStart, "", 0)
+
+		loopEnd := len(c.curFrame.instructions)
+		if jumpNotTruePos != -1 {
+			c.updateOpCodePosition(jumpNotTruePos, loopEnd)
+		}
+		c.leaveLoop(stepStart, loopEnd)
+		c.endLoop()
+		c.symbolTable = c.symbolTable.Leave()
+
+	case *ast.ForInStmtNode:
+		err := c.Compile(node.Iterable)
+		if err != nil {
+			return err
+		}
+		// el iterador se queda en la pila mientras dura el ciclo
+		c.addInstruction(code.OpIterator, 0, "", 0)
+
+		// OpIterNext deja en la pila la clave y luego el valor; cuando ya no
+		// quedan elementos saca el iterador y salta al final del ciclo.
+		loopStart := len(c.curFrame.instructions)
+		iteratorDepth := c.curFrame.depth
+		iterNextPos := c.addInstruction(code.OpIterNext, 0, "", 0)
+		c.enterLoop(iteratorDepth-1, iteratorDepth)
+
+		// la clave, el valor y el cuerpo comparten un bloque
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+		value := c.symbolTable.Define(node.Value.Value)
+		c.defineSymbol(value)
+		if node.Key != nil {
+			key := c.symbolTable.Define(node.Key.Value)
+			c.defineSymbol(key)
+		} else {
+			// descartamos la clave
+			c.addInstruction(code.OpPop, 0, "", 0)
+		}
+
+		err = c.Compile(node.Body)
+		if err != nil {
+			return err
+		}
+		c.symbolTable = c.symbolTable.Leave()
+		c.addInstruction(code.OpJump, loopStart, "", 0)
+
+		// actualizamos el salto de OpIterNext a la instrucción siguiente al ciclo
+		loopEnd := len(c.curFrame.instructions)
+		c.updateOpCodePosition(iterNextPos, loopEnd)
+		c.leaveLoop(loopStart, loopEnd)
+		// al terminar, OpIterNext ya sacó el iterador
+		c.curFrame.depth = iteratorDepth - 1
+		c.endLoop()
+
+	case *ast.BreakStmtNode:
+		// los ciclos son propios de cada frame, así que un break dentro de
+		// una función no puede salir de un ciclo que la contenga.
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("break outside loop")
+		}
+		position := c.unwindAndJump(loop.breakDepth, "break")
+		loop.breaks = append(loop.breaks, position)
+
+	case *ast.ContinueStmtNode:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("continue outside loop")
+		}
+		position := c.unwindAndJump(loop.continueDepth, "continue")
+		loop.continues = append(loop.continues, position)
+
 	case *ast.ReturnStmtNode:
 		err := c.Compile(node.Value)
 		if err != nil {
@@ -420,6 +824,144 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
+// Compila una función literal. `name` es el nombre con el que se
+// define en un let, o "" si es anónima.
+func (c *Compiler) compileFunction(node *ast.FunLiteralNode, name string) error {
+	// entramos en un nuevo ámbito de instrucciones para la función
+	c.loadFrame()
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	// compilar los parámetros y tratarlos como local bindings
+	for _, parameter := range node.Parameters {
+		c.symbolTable.Define(parameter.Value)
+	}
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	// Revisamos si la última instrucción emitida es un OpPop
+	// para cambiarla por un Return y así evitar que la
+	// máquina virtual se la cargue.
+	if c.lastInstructionIs(code.OpPop) {
+		lastIndex := len(c.curFrame.instructions) - 1
+		c.curFrame.instructions[lastIndex].OpCode = code.OpReturnValue
+	}
+	// Si no hay ni una expresión suelta (controlada arriba) ni un OpReturnValue
+	// entonces es una funcion que no retorna nada y eso es malo, para arreglarlo
+	// creamos a huevo una instrucción OpReturn que retorna null (la vm lo hará.)
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.addInstruction(code.OpReturn, 0, "null", 0)
+	}
+	// número de variables libres
+	freeSymbols := c.symbolTable.FreeSymbols
+	// Calculamos el número de variables creadas
+	// recordemos que al entrar en un nuevo symbolTable
+	// el número de definiciones empieza en cero.
+	numLocals := c.symbolTable.NumLocals()
+	cellParameters := c.useCells(len(node.Parameters))
+
+	// dejamos el ámbito y lo guardamos para la función
+	functionFrame := c.unloadFrame()
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	// creamos el objeto compiledFunction
+	functionObj := &object.CompiledFunction{
+		Instructions:   functionFrame.instructions,
+		NumLocals:      numLocals,
+		NumParameters:  len(node.Parameters),
+		CellParameters: cellParameters,
+		StrByteCode:    c.PrintInstructions(functionFrame.instructions),
+	}
+	index := c.addConstant(functionObj)
+	//c.addInstruction(code.OpConstant, index, "FUNCTION", 0)
+	c.addInstruction(code.OpClosure, index, "CLOSURE", len(freeSymbols))
+
+	return nil
+}
+
+// emite el salto de un `?.` o `?[` al final de la cadena opcional abierta
+func (c *Compiler) optionalJump() {
+	last := len(c.curFrame.chains) - 1
+	jump := c.addInstruction(code.OpJumpNull, 0, "", 0)
+	c.curFrame.chains[last] = append(c.curFrame.chains[last], jump)
+}
+
+// Devuelve el índice en el pool de un literal entero o string recién
+// compilado, o -1 si el valor es otra expresión.
+func (c *Compiler) literalIndex(value ast.Expression) int {
+	switch value.(type) {
+	case *ast.IntegerNode, *ast.StringNode:
+		return c.curFrame.instructions[len(c.curFrame.instructions)-1].Position
+	}
+	return -1
+}
+
+// error de compilación con la posición del token que lo causó
+func positionedError(tok token.Token, format string, a ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", tok.Line, tok.Column, fmt.Sprintf(format, a...))
+}
+
+// Compila un bloque en su propio ámbito: sus `let` no son visibles
+// fuera de él y ocultan a los nombres exteriores solo dentro del bloque.
+func (c *Compiler) compileBlock(block *ast.BlockStmtNode) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	err := c.Compile(block)
+	c.symbolTable = c.symbolTable.Leave()
+
+	return err
+}
+
+// Abre un ciclo en el frame actual
+func (c *Compiler) enterLoop(breakDepth, continueDepth int) {
+	c.curFrame.loops = append(c.curFrame.loops, loopContext{breakDepth: breakDepth, continueDepth: continueDepth})
+}
+
+// Devuelve el ciclo más interno del frame actual o nil
+func (c *Compiler) currentLoop() *loopContext {
+	if len(c.curFrame.loops) == 0 {
+		return nil
+	}
+	return &c.curFrame.loops[len(c.curFrame.loops)-1]
+}
+
+// Cierra el ciclo más interno actualizando sus continue y break
+func (c *Compiler) leaveLoop(continueTarget, breakTarget int) {
+	loop := c.currentLoop()
+	for _, position := range loop.continues {
+		c.updateOpCodePosition(position, continueTarget)
+	}
+	for _, position := range loop.breaks {
+		c.updateOpCodePosition(position, breakTarget)
+	}
+	c.curFrame.loops = c.curFrame.loops[:len(c.curFrame.loops)-1]
+}
+
+// Un ciclo es una sentencia: deja null como último valor extraído para
+// que el programa no termine con la condición o el iterador.
+func (c *Compiler) endLoop() {
+	c.addInstruction(code.OpNull, 0, "null", 0)
+	c.addInstruction(code.OpPop, 0, "", 0)
+}
+
+// Saca de la pila los valores por encima de `depth` y emite un salto cuyo
+// destino se actualiza al cerrar el ciclo. El código que sigue es
+// inalcanzable, así que la altura de la pila vuelve a la de antes.
+func (c *Compiler) unwindAndJump(depth int, literal string) int {
+	current := c.curFrame.depth
+	for i := depth; i < current; i++ {
+		c.addInstruction(code.OpPop, 0, "", 0)
+	}
+	position := c.addInstruction(code.OpJump, 0, literal, 0)
+	c.curFrame.depth = current
+	return position
+}
+
 // Agrega un literal u Objeto Constante en el array de objetos
 // y devuelve su indice.
 func (c *Compiler) addConstant(obj object.Object) int {
@@ -446,16 +988,60 @@ func (c *Compiler) addInstruction(opCode code.OpCode, index int, literal string,
 
 	// incrementamos el contador de instrucciones
 	c.curFrame.ic += 1
+	c.curFrame.depth += stackEffect(opCode, index, freeSymbols)
 
 	return current_position
 }
 
+// Cuántos valores agrega (o quita, si es negativo) una instrucción a la pila
+// de la vm. Para OpIterNext contamos el caso en que el ciclo continúa y
+// para OpJumpNotNull el caso en que no salta.
+func stackEffect(opCode code.OpCode, index int, freeSymbols int) int {
+	switch opCode {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetBuiltin, code.OpGetFree, code.OpCurClosure, code.OpAccessKeep,
+		code.OpCaptureFree, code.OpGetCell:
+		return 1
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow, code.OpFloorDiv,
+		code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+		code.OpLess, code.OpLessEq, code.OpGreater, code.OpGreaterEq, code.OpEqual, code.OpNotEq,
+		code.OpAnd, code.OpOr, code.OpAccess,
+		code.OpJumpNotTrue, code.OpJumpNotNull, code.OpSetGlobal, code.OpSetLocal, code.OpSetFree, code.OpReturnValue, code.OpPop,
+		code.OpNewCell, code.OpSetCell:
+		return -1
+	case code.OpSlice, code.OpSetIndex:
+		return -2
+	case code.OpArray:
+		return 1 - index
+	case code.OpHash:
+		return 1 - 2*index
+	case code.OpCall:
+		return -index
+	case code.OpClosure:
+		return 1 - freeSymbols
+	case code.OpIterNext:
+		return 2
+	}
+	return 0
+}
+
 // Elimina la última instrucción emitida
 func (c *Compiler) removeLastPop() {
 	endIndex := len(c.curFrame.instructions) - 1
 	newInstructions := c.curFrame.instructions[:endIndex]
 	c.curFrame.instructions = newInstructions
 	c.curFrame.ic -= 1 // descontamos una instrucción
+	c.curFrame.depth += 1
+}
+
+// Deja en la pila el valor de un bloque usado como expresión: el de su
+// última expresión o null si está vacío o termina en otra sentencia.
+func (c *Compiler) keepBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.addInstruction(code.OpNull, 0, "null", 0)
+	}
 }
 
 func (c *Compiler) lastInstructionIs(op code.OpCode) bool {
@@ -474,9 +1060,12 @@ func (c *Compiler) updateOpCodePosition(listPosition int, newIndex int) {
 
 // Genera y devuelve el objeto Bytecode final.
 func (c *Compiler) GetByteCode() *ByteCode {
+	// las variables de los bloques del nivel superior también se capturan
+	c.useCells(0)
 	bytecode := &ByteCode{
 		Instructions: c.curFrame.instructions,
 		ObjectPool:   c.objectPool,
+		NumLocals:    c.symbolTable.owner().NumLocals(),
 	}
 	return bytecode
 }
@@ -0,0 +1,700 @@
+package compiler
+
+import (
+	"MonkeyHabilis/ast"
+	"MonkeyHabilis/code"
+	"MonkeyHabilis/lexer"
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/parser"
+	"fmt"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instruction
+}
+
+// instrucción esperada (solo comparamos OpCode, Position y FreeSymbols)
+func ins(op code.OpCode, position int) code.Instruction {
+	return code.Instruction{OpCode: op, Position: position}
+}
+
+func closure(position int, freeSymbols int) code.Instruction {
+	return code.Instruction{OpCode: code.OpClosure, Position: position, FreeSymbols: freeSymbols}
+}
+
+func parse(t *testing.T, input string) *ast.ProgramNode {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.Program()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors)
+	}
+	return program
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("%q: compiler error: %s", tt.input, err)
+		}
+
+		bytecode := compiler.GetByteCode()
+		testInstructions(t, tt.input, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, tt.input, tt.expectedConstants, bytecode.ObjectPool)
+	}
+}
+
+func testInstructions(t *testing.T, input string, expected []code.Instruction, actual []code.Instruction) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("%q: wrong instructions length.\nwant=%s\ngot =%s", input, formatInstructions(expected), formatInstructions(actual))
+	}
+	for i, want := range expected {
+		got := actual[i]
+		if got.OpCode != want.OpCode || got.Position != want.Position || got.FreeSymbols != want.FreeSymbols {
+			t.Fatalf("%q: wrong instruction at %d.\nwant=%s\ngot =%s", input, i, formatInstructions(expected), formatInstructions(actual))
+		}
+	}
+}
+
+func formatInstructions(instructions []code.Instruction) string {
+	out := ""
+	for _, instruction := range instructions {
+		out += fmt.Sprintf("%s %d", code.OpCodeToString(instruction.OpCode), instruction.Position)
+		if instruction.FreeSymbols > 0 {
+			out += fmt.Sprintf("/%d", instruction.FreeSymbols)
+		}
+		out += "; "
+	}
+	return out
+}
+
+func testConstants(t *testing.T, input string, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("%q: wrong number of constants. want=%d, got=%d", input, len(expected), len(actual))
+	}
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("%q: constant %d - want integer %d, got=%s", input, i, constant, actual[i].Inspect())
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				t.Errorf("%q: constant %d - want string %q, got=%s", input, i, constant, actual[i].Inspect())
+			}
+		case []code.Instruction:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Fatalf("%q: constant %d - want function, got=%T", input, i, actual[i])
+			}
+			testInstructions(t, input, constant, fn.Instructions)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{"1 + 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpAdd, 0), ins(code.OpPop, 0)}},
+		{"1; 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpPop, 0), ins(code.OpConstant, 1), ins(code.OpPop, 0)}},
+		{"1 - 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpSub, 0), ins(code.OpPop, 0)}},
+		{"1 * 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpMul, 0), ins(code.OpPop, 0)}},
+		{"2 / 1", []interface{}{2, 1}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpDiv, 0), ins(code.OpPop, 0)}},
+		{"-1", []interface{}{1}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpNegInt, 0), ins(code.OpPop, 0)}},
+		{"5 % 2", []interface{}{5, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpMod, 0), ins(code.OpPop, 0)}},
+		{"5 ~/ 2", []interface{}{5, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpFloorDiv, 0), ins(code.OpPop, 0)}},
+		{"~1 & 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpBitNot, 0), ins(code.OpConstant, 1), ins(code.OpBitAnd, 0), ins(code.OpPop, 0)}},
+		{"1 | 2 ^ 3", []interface{}{1, 2, 3}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpConstant, 2), ins(code.OpBitXor, 0), ins(code.OpBitOr, 0), ins(code.OpPop, 0)}},
+		{"1 << 2 >> 3", []interface{}{1, 2, 3}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpShiftLeft, 0), ins(code.OpConstant, 2), ins(code.OpShiftRight, 0), ins(code.OpPop, 0)}},
+		{"-2 ** 3", []interface{}{2, 3}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpPow, 0), ins(code.OpNegInt, 0), ins(code.OpPop, 0)}},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{"true", []interface{}{}, []code.Instruction{ins(code.OpTrue, 0), ins(code.OpPop, 0)}},
+		{"false", []interface{}{}, []code.Instruction{ins(code.OpFalse, 0), ins(code.OpPop, 0)}},
+		{"null", []interface{}{}, []code.Instruction{ins(code.OpNull, 0), ins(code.OpPop, 0)}},
+		{"1 > 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpGreater, 0), ins(code.OpPop, 0)}},
+		{"1 < 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpLess, 0), ins(code.OpPop, 0)}},
+		{"1 >= 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpGreaterEq, 0), ins(code.OpPop, 0)}},
+		{"1 <= 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpLessEq, 0), ins(code.OpPop, 0)}},
+		{"1 == 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpEqual, 0), ins(code.OpPop, 0)}},
+		{"1 != 2", []interface{}{1, 2}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpNotEq, 0), ins(code.OpPop, 0)}},
+		{"true && false", []interface{}{}, []code.Instruction{ins(code.OpTrue, 0), ins(code.OpFalse, 0), ins(code.OpAnd, 0), ins(code.OpPop, 0)}},
+		{"true || false", []interface{}{}, []code.Instruction{ins(code.OpTrue, 0), ins(code.OpFalse, 0), ins(code.OpOr, 0), ins(code.OpPop, 0)}},
+		{"!true", []interface{}{}, []code.Instruction{ins(code.OpTrue, 0), ins(code.OpNegBool, 0), ins(code.OpPop, 0)}},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"if (true) { 10 }; 3333",
+			[]interface{}{10, 3333},
+			[]code.Instruction{
+				ins(code.OpTrue, 0),
+				ins(code.OpJumpNotTrue, 4),
+				ins(code.OpConstant, 0),
+				ins(code.OpJump, 5),
+				ins(code.OpNull, 0),
+				ins(code.OpPop, 0),
+				ins(code.OpConstant, 1),
+				ins(code.OpPop, 0),
+			},
+		},
+		{
+			"if (true) { 10 } else { 20 }; 3333",
+			[]interface{}{10, 20, 3333},
+			[]code.Instruction{
+				ins(code.OpTrue, 0),
+				ins(code.OpJumpNotTrue, 4),
+				ins(code.OpConstant, 0),
+				ins(code.OpJump, 5),
+				ins(code.OpConstant, 1),
+				ins(code.OpPop, 0),
+				ins(code.OpConstant, 2),
+				ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"let one = 1; let two = one; two",
+			[]interface{}{1},
+			[]code.Instruction{
+				ins(code.OpConstant, 0),
+				ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0),
+				ins(code.OpSetGlobal, 1),
+				ins(code.OpGetGlobal, 1),
+				ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{`"monkey"`, []interface{}{"monkey"}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpPop, 0)}},
+		{`"mon" + "key"`, []interface{}{"mon", "key"}, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpAdd, 0), ins(code.OpPop, 0)}},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"[]",
+			[]interface{}{},
+			[]code.Instruction{ins(code.OpArray, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// el operando es el número de elementos
+			"[1, 2, 3]",
+			[]interface{}{1, 2, 3},
+			[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpConstant, 2), ins(code.OpArray, 3), ins(code.OpPop, 0)},
+		},
+		{
+			"{}",
+			[]interface{}{},
+			[]code.Instruction{ins(code.OpHash, 0), ins(code.OpPop, 0)},
+		},
+		{
+			`{"a": 1}`,
+			[]interface{}{1, "a"},
+			[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpHash, 1), ins(code.OpPop, 0)},
+		},
+		{
+			"[1, 2][1]",
+			[]interface{}{1, 2, 1},
+			[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpArray, 2), ins(code.OpConstant, 2), ins(code.OpAccess, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// los extremos omitidos se compilan como null
+			`"abc"[1:]`,
+			[]interface{}{"abc", 1},
+			[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpNull, 0), ins(code.OpSlice, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// colección, índice y valor; la asignación deja el valor en la pila
+			"let a = [1]; a[0] = 2",
+			[]interface{}{1, 0, 2},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpArray, 1), ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpConstant, 1), ins(code.OpConstant, 2), ins(code.OpSetIndex, 0), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestForIn(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"for (x in [1]) { x }",
+			[]interface{}{1},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpArray, 1), ins(code.OpIterator, 0),
+				// 3: la clave se descarta y el valor se guarda en x
+				ins(code.OpIterNext, 9), ins(code.OpSetLocal, 0), ins(code.OpPop, 0),
+				ins(code.OpGetLocal, 0), ins(code.OpPop, 0), ins(code.OpJump, 3),
+				// 9: el ciclo deja null como último valor
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"for (k, v in {}) { }",
+			[]interface{}{},
+			[]code.Instruction{
+				ins(code.OpHash, 0), ins(code.OpIterator, 0),
+				ins(code.OpIterNext, 6), ins(code.OpSetLocal, 0), ins(code.OpSetLocal, 1), ins(code.OpJump, 2),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestLoopControl(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"while (true) { break; continue }",
+			[]interface{}{},
+			[]code.Instruction{
+				ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 5),
+				// break salta al final y continue a la condición
+				ins(code.OpJump, 5), ins(code.OpJump, 0), ins(code.OpJump, 0),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"for (x in []) { break }",
+			[]interface{}{},
+			[]code.Instruction{
+				ins(code.OpArray, 0), ins(code.OpIterator, 0),
+				ins(code.OpIterNext, 8), ins(code.OpSetLocal, 0), ins(code.OpPop, 0),
+				// el break saca el iterador antes de saltar
+				ins(code.OpPop, 0), ins(code.OpJump, 8), ins(code.OpJump, 2),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"for (x in []) { while (false) { continue } break }",
+			[]interface{}{},
+			[]code.Instruction{
+				ins(code.OpArray, 0), ins(code.OpIterator, 0),
+				ins(code.OpIterNext, 14), ins(code.OpSetLocal, 0), ins(code.OpPop, 0),
+				// el continue pertenece al while interno
+				ins(code.OpFalse, 0), ins(code.OpJumpNotTrue, 9), ins(code.OpJump, 5), ins(code.OpJump, 5),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+				ins(code.OpPop, 0), ins(code.OpJump, 14), ins(code.OpJump, 2),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"while (true) { [1, if (true) { break }] }",
+			[]interface{}{1},
+			[]code.Instruction{
+				ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 13),
+				ins(code.OpConstant, 0), ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 9),
+				// 5: el break saca el 1 que el arreglo ya había apilado
+				ins(code.OpPop, 0), ins(code.OpJump, 13), ins(code.OpNull, 0), ins(code.OpJump, 10),
+				ins(code.OpNull, 0), ins(code.OpArray, 2), ins(code.OpPop, 0), ins(code.OpJump, 0),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"for (x in []) { [x, if (true) { continue }] }",
+			[]interface{}{},
+			[]code.Instruction{
+				ins(code.OpArray, 0), ins(code.OpIterator, 0),
+				ins(code.OpIterNext, 16), ins(code.OpSetLocal, 0), ins(code.OpPop, 0),
+				ins(code.OpGetLocal, 0), ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 12),
+				// 8: el continue saca la x pero deja el iterador
+				ins(code.OpPop, 0), ins(code.OpJump, 2), ins(code.OpNull, 0), ins(code.OpJump, 13),
+				ins(code.OpNull, 0), ins(code.OpArray, 2), ins(code.OpPop, 0), ins(code.OpJump, 2),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestForLoop(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"for (let i = 0; i < 2; i = i + 1) { }",
+			[]interface{}{0, 2, 1},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpSetLocal, 0),
+				ins(code.OpGetLocal, 0), ins(code.OpConstant, 1), ins(code.OpLess, 0), ins(code.OpJumpNotTrue, 13),
+				// 6: el step deja el valor asignado en la pila y se descarta
+				ins(code.OpGetLocal, 0), ins(code.OpConstant, 2), ins(code.OpAdd, 0),
+				ins(code.OpSetLocal, 0), ins(code.OpGetLocal, 0), ins(code.OpPop, 0),
+				ins(code.OpJump, 2), ins(code.OpNull, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			// la i del ciclo es una local del frame principal y no es visible fuera
+			"let i = 5; for (let i = 0; false; ) { } i",
+			[]interface{}{5, 0},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpSetGlobal, 0),
+				ins(code.OpConstant, 1), ins(code.OpSetLocal, 0),
+				ins(code.OpFalse, 0), ins(code.OpJumpNotTrue, 7), ins(code.OpJump, 4),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"for (;;) { continue }",
+			[]interface{}{},
+			[]code.Instruction{ins(code.OpJump, 1), ins(code.OpJump, 0), ins(code.OpNull, 0), ins(code.OpPop, 0)},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// un bloque del nivel superior guarda sus variables en locales, no en globales
+			"if (true) { let a = 1 }; let b = 2",
+			[]interface{}{1, 2},
+			[]code.Instruction{
+				ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 6),
+				ins(code.OpConstant, 0), ins(code.OpSetLocal, 0), ins(code.OpNull, 0), ins(code.OpJump, 7),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+				ins(code.OpConstant, 1), ins(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			// dentro de una función b ocupa el índice que dejó a
+			"fn() { if (true) { let a = 1 } let b = 2; b }",
+			[]interface{}{
+				1, 2,
+				[]code.Instruction{
+					ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 6),
+					ins(code.OpConstant, 0), ins(code.OpSetLocal, 0), ins(code.OpNull, 0), ins(code.OpJump, 7),
+					ins(code.OpNull, 0), ins(code.OpPop, 0),
+					ins(code.OpConstant, 1), ins(code.OpSetLocal, 0), ins(code.OpGetLocal, 0), ins(code.OpReturnValue, 0),
+				},
+			},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// el let del bloque oculta a la x exterior solo dentro del bloque
+			"let x = 1; if (true) { let x = x + 1; x }; x",
+			[]interface{}{1, 1},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpSetGlobal, 0),
+				ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 10),
+				ins(code.OpGetGlobal, 0), ins(code.OpConstant, 1), ins(code.OpAdd, 0), ins(code.OpSetLocal, 0),
+				ins(code.OpGetLocal, 0), ins(code.OpJump, 11),
+				ins(code.OpNull, 0), ins(code.OpPop, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConstants(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// los usos de una constante literal cargan el literal directamente
+			`const a = 1; const s = "hi"; a + len(s)`,
+			[]interface{}{1, "hi"},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpSetGlobal, 0),
+				ins(code.OpConstant, 1), ins(code.OpSetGlobal, 1),
+				ins(code.OpConstant, 0), ins(code.OpGetBuiltin, 0), ins(code.OpConstant, 1), ins(code.OpCall, 1),
+				ins(code.OpAdd, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			// una clausura no necesita capturar la constante literal
+			"fn() { const a = 2; fn() { a } }",
+			[]interface{}{
+				2,
+				[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{
+					ins(code.OpConstant, 0), ins(code.OpSetLocal, 0),
+					closure(1, 0), ins(code.OpReturnValue, 0),
+				},
+			},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// si el valor no es un literal se lee la variable
+			"const b = [1]; b",
+			[]interface{}{1},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpArray, 1), ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"fn() { return 5 + 10 }",
+			[]interface{}{5, 10, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpConstant, 1), ins(code.OpAdd, 0), ins(code.OpReturnValue, 0)}},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			"fn() { 1; 2 }",
+			[]interface{}{1, 2, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpPop, 0), ins(code.OpConstant, 1), ins(code.OpReturnValue, 0)}},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			"fn() { }",
+			[]interface{}{[]code.Instruction{ins(code.OpReturn, 0)}},
+			[]code.Instruction{closure(0, 0), ins(code.OpPop, 0)},
+		},
+		{
+			"fn() { 24 }()",
+			[]interface{}{24, []code.Instruction{ins(code.OpConstant, 0), ins(code.OpReturnValue, 0)}},
+			[]code.Instruction{closure(1, 0), ins(code.OpCall, 0), ins(code.OpPop, 0)},
+		},
+		{
+			"let f = fn(a, b) { a }; f(1, 2)",
+			[]interface{}{[]code.Instruction{ins(code.OpGetLocal, 0), ins(code.OpReturnValue, 0)}, 1, 2},
+			[]code.Instruction{
+				closure(0, 0),
+				ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0),
+				ins(code.OpConstant, 1),
+				ins(code.OpConstant, 2),
+				ins(code.OpCall, 2),
+				ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestBuiltinsAndClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`len("x")`,
+			[]interface{}{"x"},
+			[]code.Instruction{ins(code.OpGetBuiltin, 0), ins(code.OpConstant, 0), ins(code.OpCall, 1), ins(code.OpPop, 0)},
+		},
+		{
+			"let num = 55; fn() { num }",
+			[]interface{}{55, []code.Instruction{ins(code.OpGetGlobal, 0), ins(code.OpReturnValue, 0)}},
+			[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpSetGlobal, 0), closure(1, 0), ins(code.OpPop, 0)},
+		},
+		{
+			"fn(a) { fn(b) { a + b } }",
+			[]interface{}{
+				[]code.Instruction{ins(code.OpGetFree, 0), ins(code.OpGetLocal, 0), ins(code.OpAdd, 0), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{ins(code.OpGetLocal, 0), closure(0, 1), ins(code.OpReturnValue, 0)},
+			},
+			[]code.Instruction{closure(1, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// funciones anidadas: lo emitido antes de la función interna no se pierde
+			"fn() { let a = 1; fn() { a } }",
+			[]interface{}{
+				1,
+				[]code.Instruction{ins(code.OpGetFree, 0), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpNewCell, 0), ins(code.OpGetLocal, 0), closure(1, 1), ins(code.OpReturnValue, 0)},
+			},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// una variable capturada vive en una celda que la función y la
+			// closure comparten, también al asignarla
+			"fn() { let a = 1; let g = fn() { a = 2 }; a }",
+			[]interface{}{
+				1,
+				2,
+				[]code.Instruction{ins(code.OpConstant, 1), ins(code.OpSetFree, 0), ins(code.OpGetFree, 0), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{ins(code.OpConstant, 0), ins(code.OpNewCell, 0), ins(code.OpGetLocal, 0), closure(2, 1), ins(code.OpSetLocal, 1), ins(code.OpGetCell, 0), ins(code.OpReturnValue, 0)},
+			},
+			[]code.Instruction{closure(3, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// la celda de una variable libre pasa tal cual a otra closure y
+			// la de un parámetro la crea la vm al llamar a la función
+			"fn(a) { fn() { fn() { a } } }",
+			[]interface{}{
+				[]code.Instruction{ins(code.OpGetFree, 0), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{ins(code.OpCaptureFree, 0), closure(0, 1), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{ins(code.OpGetLocal, 0), closure(1, 1), ins(code.OpReturnValue, 0)},
+			},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// una función se refiere a sí misma sin capturarse
+			"fn() { let f = fn() { f() }; f }",
+			[]interface{}{
+				[]code.Instruction{ins(code.OpCurClosure, 0), ins(code.OpCall, 0), ins(code.OpReturnValue, 0)},
+				[]code.Instruction{closure(0, 0), ins(code.OpSetLocal, 0), ins(code.OpGetLocal, 0), ins(code.OpReturnValue, 0)},
+			},
+			[]code.Instruction{closure(1, 0), ins(code.OpPop, 0)},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"let x = 1; x += 2",
+			[]interface{}{1, 2},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpConstant, 1), ins(code.OpAdd, 0),
+				ins(code.OpSetGlobal, 0), ins(code.OpGetGlobal, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			// x++ deja en la pila el valor anterior
+			"fn() { let x = 1; x++ }",
+			[]interface{}{
+				1, 1,
+				[]code.Instruction{
+					ins(code.OpConstant, 0), ins(code.OpSetLocal, 0),
+					ins(code.OpGetLocal, 0), ins(code.OpGetLocal, 0), ins(code.OpConstant, 1), ins(code.OpAdd, 0),
+					ins(code.OpSetLocal, 0), ins(code.OpReturnValue, 0),
+				},
+			},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// una variable capturada se actualiza en la closure
+			"fn(a) { fn() { a *= 2 } }",
+			[]interface{}{
+				2,
+				[]code.Instruction{
+					ins(code.OpGetFree, 0), ins(code.OpConstant, 0), ins(code.OpMul, 0),
+					ins(code.OpSetFree, 0), ins(code.OpGetFree, 0), ins(code.OpReturnValue, 0),
+				},
+				[]code.Instruction{ins(code.OpGetLocal, 0), closure(1, 1), ins(code.OpReturnValue, 0)},
+			},
+			[]code.Instruction{closure(2, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// la colección y el índice se evalúan una vez
+			"let a = [1]; a[0] -= 5",
+			[]interface{}{1, 0, 5},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpArray, 1), ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpConstant, 1), ins(code.OpAccessKeep, 0),
+				ins(code.OpConstant, 2), ins(code.OpSub, 0), ins(code.OpSetIndex, 0), ins(code.OpPop, 0),
+			},
+		},
+		{
+			"let a = [1]; a[0]--",
+			[]interface{}{1, 0, 1, 1},
+			[]code.Instruction{
+				ins(code.OpConstant, 0), ins(code.OpArray, 1), ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpConstant, 1), ins(code.OpAccessKeep, 0),
+				ins(code.OpConstant, 2), ins(code.OpSub, 0), ins(code.OpSetIndex, 0),
+				ins(code.OpConstant, 3), ins(code.OpAdd, 0), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConditionalOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"true ? 1 : 2",
+			[]interface{}{1, 2},
+			[]code.Instruction{
+				ins(code.OpTrue, 0), ins(code.OpJumpNotTrue, 4),
+				ins(code.OpConstant, 0), ins(code.OpJump, 5),
+				ins(code.OpConstant, 1), ins(code.OpPop, 0),
+			},
+		},
+		{
+			// el lado derecho solo se evalúa si el izquierdo es null
+			"null ?? 1",
+			[]interface{}{1},
+			[]code.Instruction{ins(code.OpNull, 0), ins(code.OpJumpNotNull, 3), ins(code.OpConstant, 0), ins(code.OpPop, 0)},
+		},
+		{
+			// cada `?.` salta al final de la cadena completa
+			`let h = {}; h?.a?[0](1)`,
+			[]interface{}{"a", 0, 1},
+			[]code.Instruction{
+				ins(code.OpHash, 0), ins(code.OpSetGlobal, 0),
+				ins(code.OpGetGlobal, 0), ins(code.OpJumpNull, 11), ins(code.OpConstant, 0), ins(code.OpAccess, 0),
+				ins(code.OpJumpNull, 11), ins(code.OpConstant, 1), ins(code.OpAccess, 0),
+				ins(code.OpConstant, 2), ins(code.OpCall, 1), ins(code.OpPop, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "undefined variable x"},
+		{"let x = 1; x.y", "undefined variable y"},
+		{"fn() { b }", "undefined variable b"},
+		{"break", "break outside loop"},
+		{"if (true) { continue }", "continue outside loop"},
+		// un break no puede salir de la función que lo contiene
+		{"while (true) { fn() { break } }", "break outside loop"},
+		{"for (let i = 0; i < 3; i = i + 1) { } i", "undefined variable i"},
+		{"x = 1", "undefined variable x"},
+		{"len = 1", "cannot assign to builtin len"},
+		{"let f = fn() { f = 1 }", "cannot assign to function f"},
+		{"let f = fn() { f += 1 }", "cannot assign to function f"},
+		{"let f = fn() { fn() { f = 1 } }", "cannot assign to function f"},
+		{"len += 1", "cannot assign to builtin len"},
+		{"y++", "undefined variable y"},
+		{"const c = 1; c++", "line 1, column 14: cannot assign to constant c"},
+		{"const c = 1; c -= 1", "line 1, column 14: cannot assign to constant c"},
+		{"const x = 1; x = 2", "line 1, column 14: cannot assign to constant x"},
+		{"const x = 1;\nlet x = 2", "line 2, column 5: cannot redefine constant x"},
+		{"const x = 1; const x = 2", "line 1, column 20: cannot redefine constant x"},
+		{"fn() { const a = [1]; fn() { a = 2 } }", "line 1, column 30: cannot assign to constant a"},
+		{"if (true) { let a = 1 }; a", "undefined variable a"},
+		{"if (false) { } else { let b = 1 }; b", "undefined variable b"},
+		{"while (false) { let w = 1 } w", "undefined variable w"},
+		{"for (x in []) { let y = x } x", "undefined variable x"},
+		{"for (k, v in {}) { } k", "undefined variable k"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(t, tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: want error %q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
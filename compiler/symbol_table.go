@@ -1,5 +1,7 @@
 package compiler
 
+import "MonkeyHabilis/object"
+
 // Alias para el tipo de alcance
 // el valor no es muy importante
 // siempre y cuando sea único
@@ -11,10 +13,11 @@ type SymbolScope string
 
 // Lista de ámbitos
 const (
-	LocalScope   SymbolScope = "LOCAL"
-	GlobalScope  SymbolScope = "GLOBAL"
-	BuiltinScope SymbolScope = "BUILTIN"
-	FreeScope    SymbolScope = "FREE"
+	LocalScope    SymbolScope = "LOCAL"
+	GlobalScope   SymbolScope = "GLOBAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
 )
 
 // contiene la información necesaria acerca
@@ -23,6 +26,11 @@ type Symbol struct {
 	Name  string
 	Scope SymbolScope
 	Index int
+	// definido con const: no se puede asignar ni redefinir
+	Constant bool
+	// un const con valor literal se carga directo del pool con OpConstant
+	HasLiteral bool
+	Literal    int
 }
 
 // asocia keys con símbolos y mantiene rastro de
@@ -33,6 +41,16 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+	// una tabla de bloque no tiene índices propios: reserva los de la
+	// tabla de la función (o la global) que la contiene.
+	block bool
+	// índice libre de la tabla dueña al abrir el bloque
+	start int
+	// locales ocupadas por los bloques del nivel superior; solo se usa en
+	// la tabla global, que guarda sus propias variables en numDefinitions
+	blockLocals int
+	// máximo de índices locales ocupados a la vez, contando bloques ya cerrados
+	maxDefinitions int
 }
 
 // Crea una tabla de símbolos
@@ -49,22 +67,102 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
-// define un símbolo
+// Crea una tabla para un bloque: sus nombres solo son visibles dentro
+// de él pero ocupan índices de la tabla que lo contiene.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	s.start = *s.owner().locals()
+
+	return s
+}
+
+// Cierra un bloque y devuelve la tabla exterior. Los índices del bloque
+// quedan libres para el siguiente.
+func (s *SymbolTable) Leave() *SymbolTable {
+	*s.owner().locals() = s.start
+	return s.Outer
+}
+
+// número de variables locales que necesita el frame de la función, o el
+// frame principal en el caso de la tabla global
+func (s *SymbolTable) NumLocals() int {
+	return s.maxDefinitions
+}
+
+// contador de índices locales de la tabla dueña. Los bloques del nivel
+// superior no crean globales: usan locales del frame principal.
+func (s *SymbolTable) locals() *int {
+	if s.Outer == nil {
+		return &s.blockLocals
+	}
+	return &s.numDefinitions
+}
+
+// devuelve la tabla de función o global que reserva los índices
+func (s *SymbolTable) owner() *SymbolTable {
+	table := s
+	for table.block {
+		table = table.Outer
+	}
+	return table
+}
+
+// define un símbolo. Redefinir un global reutiliza su índice para que las
+// funciones que lo leen vean el valor nuevo, como en el repl; una local
+// redefinida ocupa un índice nuevo.
 func (s *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{
-		Name:  name,
-		Index: s.numDefinitions,
+	if existing, ok := s.store[name]; ok && existing.Scope == GlobalScope {
+		return existing
 	}
-	// Si tenemos un parent entonces todos
+	owner := s.owner()
+	symbol := Symbol{Name: name}
+	// Si tenemos un parent, o somos un bloque, entonces
 	// nuestros binding son locales.
-	if s.Outer != nil {
-		symbol.Scope = LocalScope
-	} else {
+	if owner == s && s.Outer == nil {
 		symbol.Scope = GlobalScope
+		symbol.Index = s.numDefinitions
+		s.numDefinitions += 1
+	} else {
+		locals := owner.locals()
+		symbol.Scope = LocalScope
+		symbol.Index = *locals
+		*locals += 1
+		if *locals > owner.maxDefinitions {
+			owner.maxDefinitions = *locals
+		}
+	}
+
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// indica si `name` es un const definido en esta misma tabla
+func (s *SymbolTable) IsConstant(name string) bool {
+	symbol, ok := s.store[name]
+	return ok && symbol.Constant
+}
+
+// define un const. `literal` es el índice en el pool de su valor si es
+// un literal, o -1 si hay que leerlo de su variable.
+func (s *SymbolTable) DefineConstant(name string, literal int) Symbol {
+	symbol := s.Define(name)
+	symbol.Constant = true
+	if literal >= 0 {
+		symbol.HasLiteral = true
+		symbol.Literal = literal
 	}
+	s.store[name] = symbol
 
+	return symbol
+}
+
+// define el nombre de la función que se está compilando para que
+// pueda llamarse a sí misma con OpCurClosure
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
 	s.store[name] = symbol
-	s.numDefinitions += 1
 
 	return symbol
 }
@@ -81,11 +179,18 @@ func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	return symbol
 }
 
+// define todos los builtins del registro con su índice
+func (s *SymbolTable) DefineBuiltins(registry *object.BuiltinRegistry) {
+	for i, builtin := range registry.All() {
+		s.DefineBuiltin(i, builtin.Name)
+	}
+}
+
 // define free
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
-	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Constant: original.Constant}
 	symbol.Scope = FreeScope
 	s.store[original.Name] = symbol
 
@@ -97,10 +202,12 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
 	if !ok && s.Outer != nil {
 		obj, ok = s.Outer.Resolve(name)
-		if !ok {
+		// un bloque pertenece a la misma función que su tabla exterior
+		if !ok || s.block {
 			return obj, ok
 		}
-		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		// un const literal no se captura: se carga del pool
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope || obj.HasLiteral {
 			return obj, ok
 		}
 		free := s.defineFree(obj)
@@ -109,3 +216,38 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	}
 	return obj, ok
 }
+
+// Devuelve la tabla de la función actual (o uno de sus bloques) que
+// define la variable local con ese nombre.
+func (s *SymbolTable) definition(name string) *SymbolTable {
+	table := s
+	for table.block {
+		if symbol, ok := table.store[name]; ok && symbol.Scope == LocalScope {
+			return table
+		}
+		table = table.Outer
+	}
+	return table
+}
+
+// Devuelve el símbolo de la función exterior del que proviene una
+// variable libre.
+func (s *SymbolTable) freeOrigin(symbol Symbol) Symbol {
+	table := s.owner()
+	for symbol.Scope == FreeScope {
+		symbol = table.FreeSymbols[symbol.Index]
+		table = table.Outer.owner()
+	}
+	return symbol
+}
+
+// devuelve los nombres de todos los símbolos visibles desde esta tabla
+func (s *SymbolTable) Names() []string {
+	names := []string{}
+	for table := s; table != nil; table = table.Outer {
+		for name := range table.store {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,167 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	local := NewEnclosedSymbolTable(global)
+	b := local.Define("b")
+
+	nested := NewEnclosedSymbolTable(local)
+	c := nested.Define("c")
+
+	expected := []struct {
+		table  *SymbolTable
+		name   string
+		symbol Symbol
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{local, "b", Symbol{Name: "b", Scope: LocalScope, Index: 0}},
+		{nested, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{nested, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{nested, "len", Symbol{Name: "len", Scope: BuiltinScope, Index: 0}},
+		{nested, "b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+	}
+
+	if a.Scope != GlobalScope || b.Scope != LocalScope || c.Scope != LocalScope {
+		t.Fatalf("wrong scopes: %+v %+v %+v", a, b, c)
+	}
+	for _, tt := range expected {
+		symbol, ok := tt.table.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if symbol != tt.symbol {
+			t.Errorf("%s: want=%+v, got=%+v", tt.name, tt.symbol, symbol)
+		}
+	}
+
+	if len(nested.FreeSymbols) != 1 || nested.FreeSymbols[0] != b {
+		t.Errorf("wrong free symbols: %+v", nested.FreeSymbols)
+	}
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("local b must not be visible from the global scope")
+	}
+}
+
+func TestRedefine(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	a := global.Define("a")
+	global.Define("b")
+
+	// redefinir reutiliza el índice
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefinition of a: want=%+v, got=%+v", a, again)
+	}
+	// un global con el nombre de un builtin lo reemplaza
+	if length := global.Define("len"); length.Scope != GlobalScope || length.Index != 2 {
+		t.Errorf("wrong symbol for len: %+v", length)
+	}
+
+	// una variable local con el nombre de una libre es una nueva local
+	local := NewEnclosedSymbolTable(NewEnclosedSymbolTable(global))
+	local.Outer.Define("x")
+	local.Resolve("x")
+	if x := local.Define("x"); x.Scope != LocalScope || x.Index != 0 {
+		t.Errorf("wrong symbol for local x: %+v", x)
+	}
+	// redefinir una local no la convierte en una asignación
+	if x := local.Define("x"); x.Scope != LocalScope || x.Index != 1 {
+		t.Errorf("wrong symbol for redefined local x: %+v", x)
+	}
+}
+
+func TestDefineConstant(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineConstant("a", 3)
+	global.DefineConstant("b", -1)
+
+	if !global.IsConstant("a") || !global.IsConstant("b") {
+		t.Errorf("a and b must be constants")
+	}
+	if a, _ := global.Resolve("a"); !a.HasLiteral || a.Literal != 3 {
+		t.Errorf("wrong symbol for a: %+v", a)
+	}
+	if b, _ := global.Resolve("b"); b.HasLiteral {
+		t.Errorf("b has no literal: %+v", b)
+	}
+
+	// una función puede ocultar la constante, pero no redefinirla en su ámbito
+	local := NewEnclosedSymbolTable(NewEnclosedSymbolTable(global))
+	local.Outer.DefineConstant("c", -1)
+	if local.IsConstant("a") {
+		t.Errorf("a is not defined in the local table")
+	}
+	// la constante libre sigue siendo constante y la literal no se captura
+	if c, _ := local.Resolve("c"); c.Scope != FreeScope || !c.Constant {
+		t.Errorf("wrong symbol for free c: %+v", c)
+	}
+	local.Outer.DefineConstant("d", 7)
+	if d, _ := local.Resolve("d"); !d.HasLiteral || len(local.FreeSymbols) != 1 {
+		t.Errorf("d must not be captured: %+v, free=%+v", d, local.FreeSymbols)
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	// en el ámbito global un bloque usa locales del frame principal
+	block := NewBlockSymbolTable(global)
+	if i := block.Define("i"); i.Scope != LocalScope || i.Index != 0 {
+		t.Errorf("wrong symbol for block i: %+v", i)
+	}
+	if a := block.Define("a"); a.Scope != LocalScope || a.Index != 1 {
+		t.Errorf("block a must shadow the global a: %+v", a)
+	}
+	if _, ok := global.Resolve("i"); ok {
+		t.Errorf("block i must not be visible outside the block")
+	}
+
+	// dentro de una función comparte los índices locales y no crea libres
+	local := NewEnclosedSymbolTable(global)
+	local.Define("x")
+	inner := NewBlockSymbolTable(NewBlockSymbolTable(local))
+	if y := inner.Define("y"); y.Scope != LocalScope || y.Index != 1 {
+		t.Errorf("wrong symbol for block y: %+v", y)
+	}
+	if x, _ := inner.Resolve("x"); x.Scope != LocalScope || x.Index != 0 {
+		t.Errorf("wrong symbol for x: %+v", x)
+	}
+	if local.numDefinitions != 2 || len(local.FreeSymbols) != 0 {
+		t.Errorf("wrong function table: definitions=%d free=%+v", local.numDefinitions, local.FreeSymbols)
+	}
+
+	// al salir, el siguiente bloque reutiliza los índices locales
+	if outer := inner.Leave().Leave(); outer != local {
+		t.Errorf("Leave must return the enclosing table")
+	}
+	if z := NewBlockSymbolTable(local).Define("z"); z.Index != 1 {
+		t.Errorf("wrong symbol for z after leaving the block: %+v", z)
+	}
+	if local.NumLocals() != 2 {
+		t.Errorf("wrong number of locals: want=2, got=%d", local.NumLocals())
+	}
+
+	// al salir del bloque global sus locales quedan libres y los
+	// globales siguen con su propio contador
+	block.Leave()
+	if b := global.Define("b"); b.Scope != GlobalScope || b.Index != 1 {
+		t.Errorf("wrong symbol for global b after leaving the block: %+v", b)
+	}
+	if j := NewBlockSymbolTable(global).Define("j"); j.Index != 0 || global.NumLocals() != 2 {
+		t.Errorf("wrong symbol for j after leaving the block: %+v, locals=%d", j, global.NumLocals())
+	}
+
+	// una función dentro de un bloque global captura sus variables
+	function := NewEnclosedSymbolTable(NewBlockSymbolTable(global))
+	function.Outer.Define("k")
+	if k, _ := function.Resolve("k"); k.Scope != FreeScope || function.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("block k must be captured: %+v, free=%+v", k, function.FreeSymbols)
+	}
+}
@@ -0,0 +1,345 @@
+package evaluator
+
+import (
+	"MonkeyHabilis/ast"
+	"MonkeyHabilis/compiler"
+	"MonkeyHabilis/lexer"
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/parser"
+	"MonkeyHabilis/vm"
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// resultado comparable de una ejecución
+type outcome struct {
+	value string
+	err   string
+}
+
+// Ejecuta el programa con el compilador y la vm
+func runVM(t *testing.T, input string) outcome {
+	program := parse(t, input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		return outcome{err: err.Error()}
+	}
+
+	config := vm.DefaultConfig()
+	config.Stdout = io.Discard
+	config.Stdin = strings.NewReader("")
+	machine := vm.NewWithConfig(comp.GetByteCode(), nil, config)
+	err = machine.Run()
+	if err != nil {
+		return outcome{err: err.Error()}
+	}
+	return outcome{value: canonical(machine.LastPoppedStackElem())}
+}
+
+// Ejecuta el programa con el evaluador de referencia
+func runEvaluator(t *testing.T, input string) outcome {
+	program := parse(t, input)
+
+	e := NewWithBuiltins(object.NewBuiltinRegistry(), &object.ExecContext{
+		Out: io.Discard,
+		In:  bufio.NewReader(strings.NewReader("")),
+	})
+	result, err := e.Eval(program, e.NewEnvironment())
+	if err != nil {
+		return outcome{err: err.Error()}
+	}
+	return outcome{value: canonical(result)}
+}
+
+func parse(t *testing.T, input string) *ast.ProgramNode {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.Program()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors)
+	}
+	return program
+}
+
+// representación independiente del orden de los hashes y del tipo de función
+func canonical(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "<nil>"
+	case *object.Array:
+		elements := []string{}
+		for _, element := range obj.Elements {
+			elements = append(elements, canonical(element))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := []string{}
+		for key, value := range obj.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", key, canonical(value)))
+		}
+		sort.Strings(pairs)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *object.Closure, *object.Function:
+		return "<function>"
+	case *object.Builtin:
+		return "<builtin " + obj.Name + ">"
+	}
+	return string(obj.Type()) + ":" + obj.Inspect()
+}
+
+// programas que ambas implementaciones deben ejecutar igual
+var differentialPrograms = []string{
+	// aritmética y comparación
+	`1 + 2 * 3 - 4 / 2`,
+	`-5 + 10`,
+	`(1 + 2) * (3 + 4)`,
+	`7 / 2`,
+	`1 / 0`,
+	`-7 % 3`,
+	`-7 ~/ 3`,
+	`1 % 0`,
+	`2 ** 3 ** 2`,
+	`-2 ** 2`,
+	`2 ** 64`,
+	`2 ** -1`,
+	`true % false`,
+	`12 & 10 | 1 ^ 3`,
+	`~7 << 2 >> 1`,
+	`1 << -1`,
+	`-1 >> 100`,
+	`~"a"`,
+	`1 & true`,
+	`1 < 2`,
+	`2 <= 2`,
+	`3 > 4`,
+	`3 >= 4`,
+	`1 == 1`,
+	`1 != 1`,
+	`true == false`,
+	`true > false`,
+	`true && false`,
+	`true || false`,
+	`!true`,
+	`!!false`,
+	`!5`,
+	`-true`,
+	`1 && 2`,
+	`1 + true`,
+	`true + false`,
+	`null == null`,
+	// strings
+	`"mono" + "habilis"`,
+	`"a" - "b"`,
+	`"abc"[1]`,
+	`"abc"[3]`,
+	`"abc"["x"]`,
+	// variables y condicionales
+	`let a = 5; let b = a * 2; a + b`,
+	`x`,
+	`if (1 < 2) { 10 } else { 20 }`,
+	`if (1 > 2) { 10 } else { 20 }`,
+	`if (1 > 2) { 10 }`,
+	`let x = 3; if (x == 3) { "three" } else { "other" }`,
+	`if (1) { 2 }`,
+	`if (true) { }`,
+	`if (false) { 1 } else { }`,
+	`if (true) { let y = 1 }`,
+	`1; return 2; 3`,
+	// colecciones
+	`[]`,
+	`[1]`,
+	`[[]]`,
+	`len([])`,
+	`first([])`,
+	`push([], 1)`,
+	`[1, 2, 3]`,
+	`[1, 2, 3][0] + [1, 2, 3][2]`,
+	`[1, 2, 3][3]`,
+	`[1, 2][-1]`,
+	`[1, 2]["a"]`,
+	`[1, 2, 3, 4][1:3]`,
+	`[1, 2, 3, 4][-2:]`,
+	`[1, 2, 3][:-5]`,
+	`[1, 2, 3][2:1]`,
+	`"habilis"[2:]`,
+	`"habilis"[:-3]`,
+	`"abc"[true:]`,
+	`5[1:2]`,
+	`let a = [1, 2, 3]; a[0] = 10; a`,
+	`let a = [1, 2]; let b = a; b[1] = 5; a[1]`,
+	`let h = {"a": 1}; h["b"] = h["a"] + 1; h["b"]`,
+	`[1][1] = 2`,
+	`"abc"[0] = "z"`,
+	`let a = [0]; a[0] = a[0] = 3`,
+	`let s = 0; for (x in [1, 2, 3]) { s = s + x }; s`,
+	`let s = ""; for (k, v in {"z": 1, "a": 2}) { s = s + k }; s`,
+	`let s = ""; for (i, c in "hey") { s = c + s }; s`,
+	`let s = 0; for (i in range(-5, 5, 2)) { s = s + i }; s`,
+	`range(0)`,
+	`range(1, 2, 0)`,
+	`for (x in 1) { }`,
+	`let f = fn() { for (x in range(100)) { if (x == 7) { return x } } }; f()`,
+	`for (x in range(3)) { if (x == 1) { return x } }`,
+	`let i = 0; while (i < 10) { i = i + 1; if (i == 7) { break } }; i`,
+	`let s = 0; for (x in range(10)) { if (x / 3 * 3 == x) { continue } s = s + x }; s`,
+	`let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break } n = n + 1 } }; n`,
+	`let f = fn() { while (true) { return 4 } }; f()`,
+	// un break o continue que cruza una función se rechaza antes de ejecutar
+	`while (true) { let f = fn() { continue } }`,
+	`let n = 0; for (x in [1]) { n = fn() { [1, if (true) { break }] } }; n`,
+	`puts("antes"); let f = fn() { break }; 1`,
+	`let n = 0; for (let i = 0; i < 5; i += if (i > 1) { break } else { 1 }) { n += 1 }; n`,
+	`let f = fn() { for (let i = 0; i < 5; i += if (i > 1) { return i * 10 } else { 1 }) { } }; f()`,
+	`while (1) { break }`,
+	`let n = 0; while (n < 5000) { n = n + 1; let y = [1, if (true) { continue } else { 2 }]; } n`,
+	`for (x in [1,2,3]) { let r = [x, if (x == 2) { break } else { x }]; }`,
+	`let r = []; for (x in [1, 2, 3]) { r = [x, if (x == 2) { break } else { x }]; } r`,
+	`let f = fn() { let a = [1, if (true) { return 5 } else { 2 }]; 9 }; f()`,
+	`let f = fn() { 1 + if (true) { return 5 } else { 2 } }; f()`,
+	`let i = 0; while (i < 3) { i = i + 1 }`,
+	`let s = 0; for (x in [1, 2]) { s = s + x }`,
+	`let s = 0; for (let i = 0; i < 10; i = i + 2) { s = s + i }; s`,
+	`let i = 7; for (let i = 0; i < 3; i = i + 1) { }; i`,
+	`let s = 0; for (let i = 0; ; i = i + 1) { if (i > 4) { break } if (i == 2) { continue } s = s + i }; s`,
+	`let s = 0; for (let i = 0; i < 3; i = i + 1) { let s = 9 }; s`,
+	`let f = fn(n) { let p = 1; for (let i = 1; i <= n; i = i + 1) { p = p * i } p }; f(6)`,
+	`for (let i = 0; i; ) { }`,
+	`let x = 1; let f = fn() { let x = x + 1; x }; f() + x`,
+	`let a = [1]; let b = a; b = [2]; a[0] + b[0]`,
+	// ámbitos de bloque
+	`let x = 1; if (true) { let x = 2; } x`,
+	`if (true) { let a = 1 }; a`,
+	`let a = 5; if (true) { let a = 1; a } + a`,
+	`let y = 0; let o = []; for (x in [1, 2]) { o = push(o, y); let y = x }; o`,
+	`let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; fs[0]()`,
+	`let f = fn() { let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; fs[0]() }; f()`,
+	`let fs = []; for (i in [1, 2, 3]) { fs = push(fs, fn() { i }) }; [fs[0](), fs[1](), fs[2]()]`,
+	`let g = 0; let done = false; while (!done) { let a = 5; g = fn() { a }; done = true }; let a = 6; g()`,
+	`let g = 0; if (true) { let a = 1; g = fn() { a }; let a = 2 }; g()`,
+	`let f = fn() { let g = 0; if (true) { let a = 1; g = fn() { a }; let a = 2 }; g() }; f()`,
+	`let f = fn() { if (true) { let a = 1 } let b = 2; b }; f()`,
+	`let s = 0; for (let i = 0; i < 3; i = i + 1) { let t = i * 2; s = s + t }; s`,
+	`let g = 0; for (let i = 0; i < 2; i = i + 1) { if (i == 0) { g = fn() { i } } }; g()`,
+	// las variables capturadas se comparten; a los builtins no se les asigna
+	`let f = fn() { let a = 1; fn() { a = 2 } }; f()()`,
+	`fn() { let x = 1; let g = fn() { x += 1 }; g(); x }()`,
+	`let f = fn(a) { let set = fn(v) { a = v }; let get = fn() { fn() { a } }; set(5); get()() }; f(1)`,
+	`let f = fn() { let x = 1; let g = fn() { x }; x = 7; g() }; f()`,
+	`let x = 0; if (true) { let y = 1; let inc = fn() { y += 1 }; inc(); x = y }; x`,
+	`let fs = []; for (let i = 0; i < 3; i += 1) { fs = push(fs, fn() { i }) }; [fs[0](), fs[2]()]`,
+	`let f = fn() { fn() { f = 1 } }; f()()`,
+	`let f = fn() { let a = 1; fn() { a = 1 / 0 } }; f()()`,
+	`len = 3`,
+	`let len = 1; len = 3; len`,
+	`let f = fn() { f = 1 }; f()`,
+	`let f = fn(f) { f = 2; f }; f(1)`,
+	`let x = 1; let f = fn() { x = x + 1 }; f(); f(); x`,
+	`let f = fn() { let a = 1; let g = fn() { let a = 5; a = a + 1; a }; g() }; f()`,
+	`y = 1`,
+	`let w = fn() { let x = 1; for (let i = 0; i < 3; i = i + 1) { x = x + i }; x }; w()`,
+	`{}`,
+	`{"a": 1}`,
+	`{}["a"]`,
+	`len({})`,
+	`{"a": 1, "b": 2}`,
+	`{"a": 1, "b": 2}["b"]`,
+	`{"a": 1, "b": 2}["z"]`,
+	`{"a": 1, "b": 2}[0]`,
+	`1[0]`,
+	`[0, A]`,
+	`let h = {"one": 1, "two": 2}; h["one"] + h["two"]`,
+	// funciones y closures
+	`let add = fn(a, b) { a + b }; add(2, 3)`,
+	`let f = fn() { return 5; 10 }; f()`,
+	`let f = fn() { }; f()`,
+	`fn(a) { a * 2 }(21)`,
+	`let add = fn(a, b) { a + b }; add(1)`,
+	`5()`,
+	`let newAdder = fn(a, b) { let c = a + b; fn(d) { c + d } }; let adder = newAdder(1, 2); adder(8)`,
+	`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`,
+	`let counter = fn(x) { if (x > 100) { return x; } else { counter(x + 1) } }; counter(0)`,
+	`let outer = fn() { let a = 1; fn() { let b = 2; fn() { a + b } } }; outer()()()`,
+	// las clausuras capturan las variables que existen al crearlas
+	`let f = fn() { let a = 1; let g = fn() { a }; let a = 2; g() }; f()`,
+	`let a = 1; let g = fn() { a }; let a = 2; g()`,
+	`let f = fn() { let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5) }; f()`,
+	`let f = fn(f) { f }; f(3)`,
+	`let w = fn() { let f = fn(n) { let g = fn() { f(n - 1) }; if (n == 0) { 7 } else { g() } }; f(3) }; w()`,
+	`let f = fn() { 1 }; let g = f; let f = fn() { 2 }; g()`,
+	// un let al final deja su valor, salvo dentro de un bloque
+	`let x = 5`,
+	`let f = fn() { 1 }`,
+	`if (true) { let x = 5 }`,
+	`let f = fn() { let x = 5 }; f()`,
+	// asignación compuesta
+	`let x = 3; x += 4; x *= 2; x -= 1; x /= 3; x`,
+	`let x = 1; [x++, x--, x]`,
+	`let x = 1; x = x++ + x; x`,
+	`let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next()`,
+	`let f = fn() { let c = 0; let g = fn() { c++ }; g(); c }; f()`,
+	`let a = 5; let b = 2; [5--3, a--b, --b, - -a, a-- - 1, a]`,
+	`let f = fn() { let a = [1]; let g = fn() { a[0] += 1 }; g(); a }; f()`,
+	`let a = [1, 2]; let n = 0; let i = fn() { n++; 0 }; a[i()] -= 1; a[i()]--; [a, n]`,
+	`let h = {}; h["k"] += 1`,
+	`let h = {"k": 2}; h["k"] *= h["k"]; h`,
+	`let f = fn() { f += 1 }; f()`,
+	`let x = "s"; x--`,
+	// operadores condicionales
+	`1 < 2 ? "a" : "b"`,
+	`false ? 1 : false ? 2 : 3`,
+	`"x" ? 1 : 2`,
+	`null ?? 1`,
+	`let n = 0; let f = fn() { n++ }; [2 ?? f(), null ?? f(), n]`,
+	`let h = {"a": {"b": [1, 2]}}; [h?.a?.b?[1], h["x"]?.b?[1], h?.a?.b?[0:1]]`,
+	`let a = null; a?[0](1)[2]`,
+	`let a = [null]; a?[0][0]`,
+	`let h = {"f": fn(x) { x + 1 }}; h?.f(1) ?? 0`,
+	`let f = fn() { for (x in [1, 2, 3]) { let v = x < 2 ? if (true) { continue } : x; return v } }; f()`,
+	// pipeline
+	`[1, 2] |> push(3) |> rest()`,
+	`let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3) > 5`,
+	`"x" |> len(1)`,
+	`1 |> puts()`,
+	// lambdas
+	`let add = (a, b) => a + b; [add(1, 2), (() => "k")()]`,
+	`let adder = x => y => x + y; let f = adder(10); [f(1), f(2)]`,
+	`let map = fn(xs, f) { let out = []; for (x in xs) { out = push(out, f(x)) }; out }; [1, 2, 3] |> map(x => x * x)`,
+	`let f = x => x; f()`,
+	// constantes
+	`const a = 5; a * 2`,
+	`const a = 5; a = 6`,
+	`const a = 5; let a = 6`,
+	`const a = 5; let f = fn() { const a = 6; a }; f() + a`,
+	`let f = fn() { const a = "x"; fn() { a } }; f()()`,
+	`let f = fn() { const a = [1]; fn() { a = 2 } }; f()()`,
+	`let r = []; for (x in [1, 2]) { const c = x; r = push(r, fn() { c }); } r[0]() + r[1]()`,
+	`const k = 3`,
+	// builtins
+	`len("hola")`,
+	`len([1, 2, 3])`,
+	`len(1)`,
+	`len("a", "b")`,
+	`first([1, 2, 3])`,
+	`last([1, 2, 3])`,
+	`rest([1, 2, 3])`,
+	`push([1, 2], 3)`,
+	`puts("hola")`,
+	`len`,
+}
+
+func TestDifferential(t *testing.T) {
+	for _, input := range differentialPrograms {
+		vmResult := runVM(t, input)
+		evalResult := runEvaluator(t, input)
+
+		if vmResult != evalResult {
+			t.Errorf("%q: vm=%+v evaluator=%+v", input, vmResult, evalResult)
+		}
+	}
+}
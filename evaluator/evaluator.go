@@ -0,0 +1,988 @@
+package evaluator
+
+import (
+	"MonkeyHabilis/ast"
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/token"
+	"bufio"
+	"fmt"
+	"os"
+)
+
+var TRUE = &object.Boolean{Value: true}
+var FALSE = &object.Boolean{Value: false}
+var NULL = &object.Null{}
+
+// break y continue suben como señales hasta el ciclo más cercano, igual
+// que un return sube envuelto en object.ReturnValue hasta la función.
+type loopSignal struct {
+	keyword string
+}
+
+func (ls *loopSignal) Type() object.ObjectType { return "LOOP_SIGNAL" }
+func (ls *loopSignal) Inspect() string         { return ls.keyword }
+
+var BREAK = &loopSignal{keyword: "break"}
+var CONTINUE = &loopSignal{keyword: "continue"}
+
+// Un return, break o continue dentro de un if usado como expresión, por
+// ejemplo `[1, if (c) { break }]`, abandona la expresión que lo contiene.
+// Sube como error hasta la sentencia y ahí vuelve a ser una señal.
+type abruptCompletion struct {
+	signal object.Object
+}
+
+func (ac *abruptCompletion) Error() string { return ac.signal.Inspect() + " inside expression" }
+
+// Un `?.` o `?[` con callee null abandona la cadena opcional que lo
+// contiene. Sube como error hasta el OptionalChainNode, que vale null.
+type nullChain struct{}
+
+func (nc *nullChain) Error() string { return "null in optional chain" }
+
+var NULL_CHAIN = &nullChain{}
+
+// evalúa una sentencia recuperando la señal de una expresión abandonada
+func (e *Evaluator) evalStatement(stmt ast.Statement, env *object.Environment) (object.Object, error) {
+	value, err := e.Eval(stmt, env)
+	if abrupt, ok := err.(*abruptCompletion); ok {
+		return abrupt.signal, nil
+	}
+	return value, err
+}
+
+// Evaluator recorre el AST directamente usando los Environment del
+// paquete object. Es la implementación de referencia: debe producir
+// los mismos resultados y errores que el compilador + la vm.
+type Evaluator struct {
+	builtins    *object.BuiltinRegistry
+	execContext *object.ExecContext
+}
+
+// Crea un evaluador con los builtins estándar y los flujos del proceso
+func New() *Evaluator {
+	return NewWithBuiltins(object.NewBuiltinRegistry(), &object.ExecContext{
+		Out: os.Stdout,
+		In:  bufio.NewReader(os.Stdin),
+	})
+}
+
+// Crea un evaluador con un registro de builtins y flujos propios
+func NewWithBuiltins(builtins *object.BuiltinRegistry, execContext *object.ExecContext) *Evaluator {
+	return &Evaluator{
+		builtins:    builtins,
+		execContext: execContext,
+	}
+}
+
+// Crea el entorno global con los builtins definidos
+func (e *Evaluator) NewEnvironment() *object.Environment {
+	return object.NewEnclosedEnvironment(object.NewBuiltinEnvironment(e.builtins.All()))
+}
+
+// Evalúa un nodo y devuelve su valor. Los errores de ejecución
+// se devuelven como error igual que hace vm.Run.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) (object.Object, error) {
+	switch node := node.(type) {
+	case *ast.ProgramNode:
+		// igual que el compilador, rechaza el programa antes de ejecutarlo
+		err := checkLoopControl(node, false)
+		if err != nil {
+			return nil, err
+		}
+		var result object.Object
+		for _, stmt := range node.Statements {
+			value, err := e.evalStatement(stmt, env)
+			if err != nil {
+				return nil, err
+			}
+			// un return en el programa principal termina la ejecución
+			if returnValue, ok := value.(*object.ReturnValue); ok {
+				return returnValue.Value, nil
+			}
+			if signal, ok := value.(*loopSignal); ok {
+				return nil, fmt.Errorf("%s outside loop", signal.keyword)
+			}
+			result = value
+		}
+		return result, nil
+
+	case *ast.BlockStmtNode:
+		return e.evalBlock(node, env)
+
+	case *ast.ExpressionStmtNode:
+		return e.Eval(node.Expression, env)
+
+	case *ast.LetStmtNode:
+		if env.DefinesConstant(node.Name.Value) {
+			return nil, positionedError(node.Name.Token, "cannot redefine constant %s", node.Name.Value)
+		}
+		var value object.Object
+		var err error
+		if function, ok := node.Value.(*ast.FunLiteralNode); ok {
+			value = evalFunctionLiteral(function, node.Name.Value, env)
+		} else {
+			value, err = e.Eval(node.Value, env)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// el valor del let queda como resultado si es la última sentencia
+		if node.Constant {
+			return env.SetConstant(node.Name.Value, value), nil
+		}
+		return env.Set(node.Name.Value, value), nil
+
+	case *ast.ReturnStmtNode:
+		value, err := e.Eval(node.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		return &object.ReturnValue{Value: value}, nil
+
+	case *ast.WhileStmtNode:
+		return e.evalWhile(node, env)
+
+	case *ast.ForStmtNode:
+		return e.evalFor(node, env)
+
+	case *ast.ForInStmtNode:
+		return e.evalForIn(node, env)
+
+	case *ast.BreakStmtNode:
+		return BREAK, nil
+
+	case *ast.ContinueStmtNode:
+		return CONTINUE, nil
+
+	case *ast.IdentifierNode:
+		value, ok := env.Get(node.Value)
+		if !ok {
+			return nil, fmt.Errorf("undefined variable %s", node.Value)
+		}
+		return value, nil
+
+	case *ast.IntegerNode:
+		return &object.Integer{Value: node.Value}, nil
+
+	case *ast.StringNode:
+		return &object.String{Value: node.Value}, nil
+
+	case *ast.BooleanNode:
+		return nativeBoolToBooleanObject(node.Value), nil
+
+	case *ast.NullNode:
+		return NULL, nil
+
+	case *ast.Binary:
+		// igual que la vm: ambos operandos se evalúan siempre
+		left, err := e.Eval(node.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.Eval(node.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		return evalBinary(node.Op, left, right)
+
+	case *ast.Unary:
+		right, err := e.Eval(node.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		return evalUnary(node.Op, right)
+
+	case *ast.IfExprNode:
+		return e.evalIf(node, env)
+
+	case *ast.FunLiteralNode:
+		return evalFunctionLiteral(node, "", env), nil
+
+	case *ast.CallExprNode:
+		callee, err := e.Eval(node.Callee, env)
+		if err != nil {
+			return nil, err
+		}
+		args, err := e.evalExpressions(node.Arguments, env)
+		if err != nil {
+			return nil, err
+		}
+		return e.applyFunction(callee, args)
+
+	case *ast.ArrayLiteralNode:
+		elements, err := e.evalExpressions(node.Elements, env)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case *ast.HashLiteralNode:
+		return e.evalHashLiteral(node, env)
+
+	case *ast.IndexExprNode:
+		collection, err := e.Eval(node.Callee, env)
+		if err != nil {
+			return nil, err
+		}
+		if node.Optional && isNull(collection) {
+			return nil, NULL_CHAIN
+		}
+		index, err := e.Eval(node.Index, env)
+		if err != nil {
+			return nil, err
+		}
+		return evalIndex(collection, index)
+
+	case *ast.AssignExprNode:
+		switch target := node.Target.(type) {
+		case *ast.IdentifierNode:
+			// el compilador valida el destino antes de compilar el valor
+			if env.IsConstant(target.Value) {
+				return nil, positionedError(target.Token, "cannot assign to constant %s", target.Value)
+			}
+			err := env.CanAssign(target.Value)
+			if err != nil {
+				return nil, err
+			}
+			value, err := e.Eval(node.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			return value, env.Assign(target.Value, value)
+
+		case *ast.IndexExprNode:
+			values, err := e.evalExpressions([]ast.Expression{target.Callee, target.Index, node.Value}, env)
+			if err != nil {
+				return nil, err
+			}
+			return evalSetIndex(values[0], values[1], values[2])
+		}
+		return nil, fmt.Errorf("invalid assignment target: %s", node.Target)
+
+	case *ast.CompoundAssignExprNode:
+		return e.evalCompoundAssign(node, env)
+
+	case *ast.OptionalChainNode:
+		value, err := e.Eval(node.Chain, env)
+		if err == NULL_CHAIN {
+			return NULL, nil
+		}
+		return value, err
+
+	case *ast.CoalesceExprNode:
+		left, err := e.Eval(node.Left, env)
+		if err != nil || !isNull(left) {
+			return left, err
+		}
+		return e.Eval(node.Right, env)
+
+	case *ast.ConditionalExprNode:
+		condition, err := e.Eval(node.Condition, env)
+		if err != nil {
+			return nil, err
+		}
+		truthy, err := isTruthy(condition)
+		if err != nil {
+			return nil, err
+		}
+		if truthy {
+			return e.Eval(node.Consequence, env)
+		}
+		return e.Eval(node.Alternative, env)
+
+	case *ast.SliceExprNode:
+		collection, err := e.Eval(node.Callee, env)
+		if err != nil {
+			return nil, err
+		}
+		if node.Optional && isNull(collection) {
+			return nil, NULL_CHAIN
+		}
+		bounds := []object.Object{NULL, NULL}
+		for i, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				continue
+			}
+			bounds[i], err = e.Eval(bound, env)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return evalSlice(collection, bounds[0], bounds[1])
+	}
+	return nil, nil
+}
+
+// evalúa un bloque y devuelve el valor de su última expresión.
+// Un `return` se propaga envuelto en object.ReturnValue.
+func (e *Evaluator) evalBlock(block *ast.BlockStmtNode, env *object.Environment) (object.Object, error) {
+	var result object.Object = NULL
+	for _, stmt := range block.Statements {
+		value, err := e.evalStatement(stmt, env)
+		if err != nil {
+			return nil, err
+		}
+		switch value.(type) {
+		case *object.ReturnValue, *loopSignal:
+			return value, nil
+		}
+		// como en el compilador, solo una expresión final da valor al bloque
+		result = NULL
+		if _, ok := stmt.(*ast.ExpressionStmtNode); ok {
+			result = value
+		}
+	}
+	return result, nil
+}
+
+// evalúa el cuerpo de un if o de un ciclo en su propio entorno para que
+// sus `let` no sean visibles fuera de él
+func (e *Evaluator) evalScopedBlock(block *ast.BlockStmtNode, env *object.Environment) (object.Object, error) {
+	return e.evalBlock(block, object.NewBlockEnvironment(env))
+}
+
+// crea la clausura compartiendo las variables locales visibles.
+// Como en la vm, la función ve su propio nombre aunque se defina dentro
+// de otra, y tampoco puede asignarlo.
+func evalFunctionLiteral(node *ast.FunLiteralNode, name string, env *object.Environment) *object.Function {
+	parameters := []*ast.IdentifierNode{}
+	for i := range node.Parameters {
+		parameters = append(parameters, &node.Parameters[i])
+	}
+	function := &object.Function{Parameters: parameters, Body: node.Body, Env: env.Capture()}
+	if name != "" {
+		function.Env.SetSelf(name, function)
+	}
+	return function
+}
+
+// x op= v, x++ y x--. Igual que la vm, el destino se lee antes de evaluar
+// el valor y la colección y el índice de `a[i] += v` se evalúan una vez.
+func (e *Evaluator) evalCompoundAssign(node *ast.CompoundAssignExprNode, env *object.Environment) (object.Object, error) {
+	var store func(object.Object) error
+	var old object.Object
+
+	switch target := node.Target.(type) {
+	case *ast.IdentifierNode:
+		if env.IsConstant(target.Value) {
+			return nil, positionedError(target.Token, "cannot assign to constant %s", target.Value)
+		}
+		err := env.CanAssign(target.Value)
+		if err != nil {
+			return nil, err
+		}
+		old, _ = env.Get(target.Value)
+		store = func(value object.Object) error {
+			return env.Assign(target.Value, value)
+		}
+
+	case *ast.IndexExprNode:
+		values, err := e.evalExpressions([]ast.Expression{target.Callee, target.Index}, env)
+		if err != nil {
+			return nil, err
+		}
+		old, err = evalIndex(values[0], values[1])
+		if err != nil {
+			return nil, err
+		}
+		store = func(value object.Object) error {
+			_, err := evalSetIndex(values[0], values[1], value)
+			return err
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid assignment target: %s", node.Target)
+	}
+
+	value, err := e.Eval(node.Value, env)
+	if err != nil {
+		return nil, err
+	}
+	result, err := evalBinary(node.Op, old, value)
+	if err != nil {
+		return nil, err
+	}
+	err = store(result)
+	if err != nil {
+		return nil, err
+	}
+	if node.Postfix {
+		return old, nil
+	}
+	return result, nil
+}
+
+// while: repite el cuerpo mientras la condición sea verdadera
+func (e *Evaluator) evalWhile(node *ast.WhileStmtNode, env *object.Environment) (object.Object, error) {
+	for {
+		condition, err := e.Eval(node.Condition, env)
+		if err != nil {
+			return nil, err
+		}
+		truthy, err := isTruthy(condition)
+		if err != nil {
+			return nil, err
+		}
+		if !truthy {
+			return NULL, nil
+		}
+		value, err := e.evalScopedBlock(node.Body, env)
+		if err != nil {
+			return nil, err
+		}
+		if value == BREAK {
+			return NULL, nil
+		}
+		if _, ok := value.(*object.ReturnValue); ok {
+			return value, nil
+		}
+	}
+}
+
+// for clásico: las variables del init viven en un entorno propio del ciclo
+func (e *Evaluator) evalFor(node *ast.ForStmtNode, env *object.Environment) (object.Object, error) {
+	loopEnv := object.NewBlockEnvironment(env)
+	if node.Init != nil {
+		_, err := e.Eval(node.Init, loopEnv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			condition, err := e.Eval(node.Condition, loopEnv)
+			if err != nil {
+				return nil, err
+			}
+			truthy, err := isTruthy(condition)
+			if err != nil {
+				return nil, err
+			}
+			if !truthy {
+				return NULL, nil
+			}
+		}
+		value, err := e.evalScopedBlock(node.Body, loopEnv)
+		if err != nil {
+			return nil, err
+		}
+		if value == BREAK {
+			return NULL, nil
+		}
+		if _, ok := value.(*object.ReturnValue); ok {
+			return value, nil
+		}
+		// el paso es parte del ciclo: igual que en la vm, un break dentro
+		// de él termina el ciclo y un continue vuelve a empezar el paso
+		for node.Step != nil {
+			value, err := e.evalStatement(&ast.ExpressionStmtNode{Expression: node.Step}, loopEnv)
+			if err != nil {
+				return nil, err
+			}
+			if value == BREAK {
+				return NULL, nil
+			}
+			if _, ok := value.(*object.ReturnValue); ok {
+				return value, nil
+			}
+			if value != CONTINUE {
+				break
+			}
+		}
+	}
+}
+
+// for-in: recorre cualquier object.Iterable con su iterador
+func (e *Evaluator) evalForIn(node *ast.ForInStmtNode, env *object.Environment) (object.Object, error) {
+	collection, err := e.Eval(node.Iterable, env)
+	if err != nil {
+		return nil, err
+	}
+	iterable, ok := collection.(object.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("cannot iterate over %s", collection.Type())
+	}
+
+	iterator := iterable.Iterator()
+	for {
+		key, value, ok := iterator.Next()
+		if !ok {
+			return NULL, nil
+		}
+		// la clave, el valor y el cuerpo comparten el entorno de la iteración
+		iterationEnv := object.NewBlockEnvironment(env)
+		iterationEnv.Set(node.Value.Value, value)
+		if node.Key != nil {
+			iterationEnv.Set(node.Key.Value, key)
+		}
+		result, err := e.evalBlock(node.Body, iterationEnv)
+		if err != nil {
+			return nil, err
+		}
+		if result == BREAK {
+			return NULL, nil
+		}
+		if _, ok := result.(*object.ReturnValue); ok {
+			return result, nil
+		}
+	}
+}
+
+// if: la condición debe ser booleana; sin else el valor es null
+func (e *Evaluator) evalIf(node *ast.IfExprNode, env *object.Environment) (object.Object, error) {
+	condition, err := e.Eval(node.Condition, env)
+	if err != nil {
+		return nil, err
+	}
+	truthy, err := isTruthy(condition)
+	if err != nil {
+		return nil, err
+	}
+	block := node.Alternative
+	if truthy {
+		block = node.Consequence
+	}
+	if block == nil {
+		return NULL, nil
+	}
+	value, err := e.evalScopedBlock(block, env)
+	if err != nil {
+		return nil, err
+	}
+	switch value.(type) {
+	case *object.ReturnValue, *loopSignal:
+		return nil, &abruptCompletion{signal: value}
+	}
+	return value, nil
+}
+
+// evalúa una lista de expresiones de izquierda a derecha
+func (e *Evaluator) evalExpressions(expressions []ast.Expression, env *object.Environment) ([]object.Object, error) {
+	result := []object.Object{}
+	for _, expression := range expressions {
+		value, err := e.Eval(expression, env)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// igual que la vm, las claves se guardan por su representación en string
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteralNode, env *object.Environment) (object.Object, error) {
+	pairs := make(map[string]object.Object)
+	for keyNode, valueNode := range node.Pairs {
+		value, err := e.Eval(valueNode, env)
+		if err != nil {
+			return nil, err
+		}
+		key, err := e.Eval(keyNode, env)
+		if err != nil {
+			return nil, err
+		}
+		pairs[key.Inspect()] = value
+	}
+	return &object.Hash{Pairs: pairs}, nil
+}
+
+// invoca una función o un builtin
+func (e *Evaluator) applyFunction(callee object.Object, args []object.Object) (object.Object, error) {
+	switch fn := callee.(type) {
+	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return nil, fmt.Errorf("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		env := object.NewFunctionEnvironment(fn.Env)
+		for i, parameter := range fn.Parameters {
+			env.Set(parameter.Value, args[i])
+		}
+		value, err := e.evalBlock(fn.Body, env)
+		if err != nil {
+			return nil, err
+		}
+		if returnValue, ok := value.(*object.ReturnValue); ok {
+			return returnValue.Value, nil
+		}
+		// el compilador rechaza un break que cruce la función
+		if signal, ok := value.(*loopSignal); ok {
+			return nil, fmt.Errorf("%s outside loop", signal.keyword)
+		}
+		return value, nil
+
+	case *object.Builtin:
+		if fn.Arity != object.VARIADIC && len(args) != fn.Arity {
+			return nil, fmt.Errorf("wrong number of arguments for %s: want=%d, got=%d", fn.Name, fn.Arity, len(args))
+		}
+		result := fn.Fn(e.execContext, args...)
+		if result == nil {
+			return NULL, nil
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("calling non-function and non-built-in")
+}
+
+// la condición de un if o while debe ser booleana
+func isTruthy(condition object.Object) (bool, error) {
+	boolean, ok := condition.(*object.Boolean)
+	if !ok {
+		return false, fmt.Errorf("non-boolean condition: %s", condition.Type())
+	}
+	return boolean.Value, nil
+}
+
+func isNull(obj object.Object) bool {
+	_, ok := obj.(*object.Null)
+	return ok
+}
+
+func nativeBoolToBooleanObject(value bool) *object.Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+// mismo formato que los errores de compilación con posición
+func positionedError(tok token.Token, format string, a ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", tok.Line, tok.Column, fmt.Sprintf(format, a...))
+}
+
+// operadores unarios: `!` solo para booleanos y `-` solo para enteros
+func evalUnary(op token.Token, right object.Object) (object.Object, error) {
+	switch op.Type {
+	case token.BANG:
+		boolean, ok := right.(*object.Boolean)
+		if !ok {
+			return nil, fmt.Errorf("invalid type for this operation %s", right.Type())
+		}
+		return nativeBoolToBooleanObject(!boolean.Value), nil
+	case token.MINUS:
+		integer, ok := right.(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("invalid type for this operation %s", right.Type())
+		}
+		return &object.Integer{Value: integer.Value * -1}, nil
+	case token.BIT_NOT:
+		integer, ok := right.(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("invalid type for this operation %s", right.Type())
+		}
+		return &object.Integer{Value: ^integer.Value}, nil
+	}
+	return nil, fmt.Errorf("unknown operator for unary expression %s", op.Literal)
+}
+
+// operadores binarios con las mismas reglas de tipos que la vm
+func evalBinary(op token.Token, left object.Object, right object.Object) (object.Object, error) {
+	switch op.Type {
+	case token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.PERCENT, token.POWER, token.FLOORDIV,
+		token.BIT_AND, token.BIT_OR, token.BIT_XOR, token.SHIFT_LEFT, token.SHIFT_RIGHT, token.LT, token.LT_EQ,
+		token.GT, token.GT_EQ, token.EQ, token.NOT_EQ, token.AND, token.OR:
+	default:
+		return nil, fmt.Errorf("unknown operator %s", op.Literal)
+	}
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerBinary(op, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		if op.Type != token.PLUS {
+			return nil, fmt.Errorf("unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+		}
+		return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}, nil
+	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
+		return evalBooleanBinary(op, left, right)
+	}
+	return nil, fmt.Errorf("unsupported types for binary operation: %s %s", left.Type(), right.Type())
+}
+
+func evalIntegerBinary(op token.Token, left object.Object, right object.Object) (object.Object, error) {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch op.Type {
+	case token.PLUS:
+		return &object.Integer{Value: leftValue + rightValue}, nil
+	case token.MINUS:
+		return &object.Integer{Value: leftValue - rightValue}, nil
+	case token.ASTERISK:
+		return &object.Integer{Value: leftValue * rightValue}, nil
+	case token.SLASH:
+		if rightValue == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Integer{Value: leftValue / rightValue}, nil
+	case token.PERCENT:
+		if rightValue == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Integer{Value: object.FloorMod(leftValue, rightValue)}, nil
+	case token.FLOORDIV:
+		if rightValue == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Integer{Value: object.FloorDiv(leftValue, rightValue)}, nil
+	case token.POWER:
+		if rightValue < 0 {
+			return nil, fmt.Errorf("negative exponent: %d", rightValue)
+		}
+		value, ok := object.Power(leftValue, rightValue)
+		if !ok {
+			return nil, fmt.Errorf("integer overflow: %d ** %d", leftValue, rightValue)
+		}
+		return &object.Integer{Value: value}, nil
+	case token.BIT_AND:
+		return &object.Integer{Value: leftValue & rightValue}, nil
+	case token.BIT_OR:
+		return &object.Integer{Value: leftValue | rightValue}, nil
+	case token.BIT_XOR:
+		return &object.Integer{Value: leftValue ^ rightValue}, nil
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT:
+		if rightValue < 0 {
+			return nil, fmt.Errorf("negative shift count: %d", rightValue)
+		}
+		if op.Type == token.SHIFT_LEFT {
+			return &object.Integer{Value: leftValue << rightValue}, nil
+		}
+		return &object.Integer{Value: leftValue >> rightValue}, nil
+	case token.LT:
+		return nativeBoolToBooleanObject(leftValue < rightValue), nil
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftValue <= rightValue), nil
+	case token.GT:
+		return nativeBoolToBooleanObject(leftValue > rightValue), nil
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftValue >= rightValue), nil
+	case token.EQ:
+		return nativeBoolToBooleanObject(leftValue == rightValue), nil
+	case token.NOT_EQ:
+		return nativeBoolToBooleanObject(leftValue != rightValue), nil
+	}
+	return nil, fmt.Errorf("unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+}
+
+// los booleanos se comparan como 0 y 1, y son los únicos que aceptan && y ||
+func evalBooleanBinary(op token.Token, left object.Object, right object.Object) (object.Object, error) {
+	leftValue := left.(*object.Boolean).Value
+	rightValue := right.(*object.Boolean).Value
+
+	switch op.Type {
+	case token.AND:
+		return nativeBoolToBooleanObject(leftValue && rightValue), nil
+	case token.OR:
+		return nativeBoolToBooleanObject(leftValue || rightValue), nil
+	case token.LT, token.LT_EQ, token.GT, token.GT_EQ, token.EQ, token.NOT_EQ:
+		leftInteger := &object.Integer{Value: 0}
+		rightInteger := &object.Integer{Value: 0}
+		if leftValue {
+			leftInteger.Value = 1
+		}
+		if rightValue {
+			rightInteger.Value = 1
+		}
+		return evalIntegerBinary(op, leftInteger, rightInteger)
+	}
+	return nil, fmt.Errorf("unsupported operator for binary operation %s %s", left.Type(), right.Type())
+}
+
+// acceso por índice a arrays, hashes y strings
+func evalIndex(collection object.Object, index object.Object) (object.Object, error) {
+	switch collection := collection.(type) {
+	case *object.Array:
+		if index.Type() != object.INTEGER_OBJ {
+			return nil, fmt.Errorf("invalid subscript data type for array access %s", index.Type())
+		}
+		i := int(index.(*object.Integer).Value)
+		if i < 0 || i >= len(collection.Elements) {
+			return nil, fmt.Errorf("index out of range")
+		}
+		return collection.Elements[i], nil
+
+	case *object.Hash:
+		if index.Type() != object.STRING_OBJ {
+			return nil, fmt.Errorf("invalid subscript data type for array access %s", index.Type())
+		}
+		if value, ok := collection.Pairs[index.(*object.String).Value]; ok {
+			return value, nil
+		}
+		return NULL, nil
+
+	case *object.String:
+		if index.Type() != object.INTEGER_OBJ {
+			return nil, fmt.Errorf("invalid subscript data type for array access %s", index.Type())
+		}
+		i := int(index.(*object.Integer).Value)
+		if i < 0 || i >= len(collection.Value) {
+			return nil, fmt.Errorf("index out of range")
+		}
+		return &object.String{Value: string(collection.Value[i])}, nil
+	}
+	return nil, fmt.Errorf("index operator not supported: %s", collection.Type())
+}
+
+// collection[index] = value modificando la colección en su lugar
+func evalSetIndex(collection object.Object, index object.Object, value object.Object) (object.Object, error) {
+	switch collection := collection.(type) {
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return nil, fmt.Errorf("invalid subscript data type for array access %s", index.Type())
+		}
+		if integer.Value < 0 || integer.Value >= int64(len(collection.Elements)) {
+			return nil, fmt.Errorf("index out of range")
+		}
+		collection.Elements[integer.Value] = value
+		return value, nil
+
+	case *object.Hash:
+		key, ok := index.(*object.String)
+		if !ok {
+			return nil, fmt.Errorf("invalid subscript data type for array access %s", index.Type())
+		}
+		collection.Pairs[key.Value] = value
+		return value, nil
+	}
+	return nil, fmt.Errorf("index assignment not supported: %s", collection.Type())
+}
+
+// collection[start:end] sobre arrays y strings
+func evalSlice(collection object.Object, start object.Object, end object.Object) (object.Object, error) {
+	switch collection := collection.(type) {
+	case *object.Array:
+		low, high, err := object.SliceBounds(len(collection.Elements), start, end)
+		if err != nil {
+			return nil, err
+		}
+		elements := make([]object.Object, high-low)
+		copy(elements, collection.Elements[low:high])
+		return &object.Array{Elements: elements}, nil
+
+	case *object.String:
+		low, high, err := object.SliceBounds(len(collection.Value), start, end)
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: collection.Value[low:high]}, nil
+	}
+	return nil, fmt.Errorf("slice operator not supported: %s", collection.Type())
+}
+
+// Busca un break o continue fuera de un ciclo, como hace el compilador.
+// Los ciclos son propios de cada función, así que una función dentro de un
+// ciclo no puede usarlos. La condición de un while o un for y el iterable
+// de un for-in quedan fuera de su ciclo; el paso de un for, dentro.
+func checkLoopControl(node ast.Node, inLoop bool) error {
+	switch node := node.(type) {
+	case *ast.ProgramNode:
+		for _, stmt := range node.Statements {
+			err := checkLoopControl(stmt, inLoop)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.BlockStmtNode:
+		if node == nil {
+			return nil
+		}
+		for _, stmt := range node.Statements {
+			err := checkLoopControl(stmt, inLoop)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.BreakStmtNode:
+		if !inLoop {
+			return fmt.Errorf("break outside loop")
+		}
+	case *ast.ContinueStmtNode:
+		if !inLoop {
+			return fmt.Errorf("continue outside loop")
+		}
+	case *ast.FunLiteralNode:
+		return checkLoopControl(node.Body, false)
+	case *ast.WhileStmtNode:
+		err := checkLoopControl(node.Condition, inLoop)
+		if err != nil {
+			return err
+		}
+		return checkLoopControl(node.Body, true)
+	case *ast.ForStmtNode:
+		for _, part := range []ast.Node{node.Init, node.Condition} {
+			err := checkLoopControl(part, inLoop)
+			if err != nil {
+				return err
+			}
+		}
+		err := checkLoopControl(node.Body, true)
+		if err != nil {
+			return err
+		}
+		return checkLoopControl(node.Step, true)
+	case *ast.ForInStmtNode:
+		err := checkLoopControl(node.Iterable, inLoop)
+		if err != nil {
+			return err
+		}
+		return checkLoopControl(node.Body, true)
+	case *ast.LetStmtNode:
+		return checkLoopControl(node.Value, inLoop)
+	case *ast.ReturnStmtNode:
+		return checkLoopControl(node.Value, inLoop)
+	case *ast.ExpressionStmtNode:
+		return checkLoopControl(node.Expression, inLoop)
+	case *ast.IfExprNode:
+		for _, part := range []ast.Node{node.Condition, node.Consequence, node.Alternative} {
+			err := checkLoopControl(part, inLoop)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		for _, expression := range subexpressions(node) {
+			err := checkLoopControl(expression, inLoop)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// devuelve las subexpresiones de una expresión sin bloques propios
+func subexpressions(node ast.Node) []ast.Expression {
+	switch node := node.(type) {
+	case *ast.Binary:
+		return []ast.Expression{node.Left, node.Right}
+	case *ast.Unary:
+		return []ast.Expression{node.Right}
+	case *ast.CallExprNode:
+		return append([]ast.Expression{node.Callee}, node.Arguments...)
+	case *ast.IndexExprNode:
+		return []ast.Expression{node.Callee, node.Index}
+	case *ast.SliceExprNode:
+		return []ast.Expression{node.Callee, node.Start, node.End}
+	case *ast.AssignExprNode:
+		return []ast.Expression{node.Target, node.Value}
+	case *ast.CompoundAssignExprNode:
+		return []ast.Expression{node.Target, node.Value}
+	case *ast.ConditionalExprNode:
+		return []ast.Expression{node.Condition, node.Consequence, node.Alternative}
+	case *ast.CoalesceExprNode:
+		return []ast.Expression{node.Left, node.Right}
+	case *ast.OptionalChainNode:
+		return []ast.Expression{node.Chain}
+	case *ast.ArrayLiteralNode:
+		return node.Elements
+	case *ast.HashLiteralNode:
+		expressions := []ast.Expression{}
+		for key, value := range node.Pairs {
+			expressions = append(expressions, key, value)
+		}
+		return expressions
+	}
+	return nil
+}
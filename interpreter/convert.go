@@ -0,0 +1,115 @@
+package interpreter
+
+import (
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/vm"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Convierte un valor Go en un object.Object.
+// Soporta nil, bool, enteros, string, slices, mapas con claves string,
+// funciones builtin y objetos que ya sean object.Object.
+func ToObject(value interface{}) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return vm.NULL, nil
+	case object.Object:
+		return value, nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Arity: object.VARIADIC, Fn: value}, nil
+	case func(ctx *object.ExecContext, args ...object.Object) object.Object:
+		return &object.Builtin{Arity: object.VARIADIC, Fn: value}, nil
+	case bool:
+		if value {
+			return vm.TRUE, nil
+		}
+		return vm.FALSE, nil
+	case string:
+		return &object.String{Value: value}, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// los enteros del lenguaje son de 64 bits con signo
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("integer %d overflows int64", rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			element, err := ToObject(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", rv.Type().Key())
+		}
+		pairs := make(map[string]object.Object, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			pair, err := ToObject(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			pairs[iter.Key().String()] = pair
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported Go type %T", value)
+}
+
+// Convierte un object.Object en su valor Go equivalente:
+// Integer -> int64, Boolean -> bool, String -> string, Null -> nil,
+// Array -> []interface{} y Hash -> map[string]interface{}.
+// Los demás objetos (funciones, closures) se devuelven tal cual.
+// Un array o hash que se contiene a sí mismo se corta con nil.
+func FromObject(obj object.Object) interface{} {
+	return fromObject(obj, map[object.Object]bool{})
+}
+
+// `seen` guarda las colecciones que se están convirtiendo
+func fromObject(obj object.Object, seen map[object.Object]bool) interface{} {
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		if seen[obj] {
+			return nil
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+	}
+
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = fromObject(element, seen)
+		}
+		return elements
+	case *object.Hash:
+		pairs := make(map[string]interface{}, len(obj.Pairs))
+		for key, value := range obj.Pairs {
+			pairs[key] = fromObject(value, seen)
+		}
+		return pairs
+	}
+	return obj
+}
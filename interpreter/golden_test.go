@@ -0,0 +1,63 @@
+package interpreter
+
+import (
+	"MonkeyHabilis/vm"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test ./interpreter -update regenera los archivos .golden
+var update = flag.Bool("update", false, "update the .golden files")
+
+// Ejecuta cada programa de testdata y compara la salida (lo que imprime
+// más el valor final o el error) con su archivo .golden.
+func TestGoldenPrograms(t *testing.T) {
+	programs, err := filepath.Glob(filepath.Join("testdata", "*.mh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(programs) == 0 {
+		t.Fatal("no programs found in testdata")
+	}
+
+	for _, program := range programs {
+		t.Run(filepath.Base(program), func(t *testing.T) {
+			source, err := os.ReadFile(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			config := vm.DefaultConfig()
+			config.Builtins = nil
+			config.Stdout = &out
+			config.Stdin = strings.NewReader("")
+
+			result, err := NewWithConfig(config).Eval(string(source))
+			if err != nil {
+				fmt.Fprintf(&out, "error: %s\n", err)
+			} else {
+				fmt.Fprintf(&out, "=> %s\n", result.Inspect())
+			}
+
+			golden := strings.TrimSuffix(program, ".mh") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(expected) {
+				t.Errorf("output mismatch\nwant:\n%s\ngot:\n%s", expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package interpreter
+
+import (
+	"MonkeyHabilis/compiler"
+	"MonkeyHabilis/lexer"
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/parser"
+	"MonkeyHabilis/vm"
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Interpreter encapsula el estado que sobrevive entre evaluaciones
+// (constantes, globales y tabla de símbolos) para que un programa Go
+// pueda embeber scripts sin repetir el cableado de la REPL.
+type Interpreter struct {
+	objectPool  []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+	builtins    *object.BuiltinRegistry
+	config      vm.Config
+}
+
+// Errores de sintaxis encontrados por el parser
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	messages := []string{}
+	for _, msg := range e.Messages {
+		messages = append(messages, strings.TrimSpace(msg))
+	}
+	return "parser errors: " + strings.Join(messages, "; ")
+}
+
+// Crea un intérprete con los builtins y límites por defecto
+func New() *Interpreter {
+	config := vm.DefaultConfig()
+	config.Builtins = nil
+
+	return NewWithConfig(config)
+}
+
+// Crea un intérprete cuyas vms usan la configuración indicada.
+// Si config.Builtins es nil el intérprete crea su propio registro.
+func NewWithConfig(config vm.Config) *Interpreter {
+	if config.Builtins == nil {
+		config.Builtins = object.NewBuiltinRegistry()
+	}
+	// todas las vms del intérprete comparten el mismo buffer de entrada
+	if config.Stdin != nil {
+		if _, ok := config.Stdin.(*bufio.Reader); !ok {
+			config.Stdin = bufio.NewReader(config.Stdin)
+		}
+	}
+	symbolTable := compiler.NewSymbolTable()
+	symbolTable.DefineBuiltins(config.Builtins)
+
+	return &Interpreter{
+		objectPool:  []object.Object{},
+		globals:     []object.Object{},
+		symbolTable: symbolTable,
+		builtins:    config.Builtins,
+		config:      config,
+	}
+}
+
+// Expone una función Go a los scripts de este intérprete.
+// `arity` es el número de argumentos esperado o object.VARIADIC.
+func (i *Interpreter) RegisterBuiltin(name string, arity int, fn object.BuiltinFunction, doc string) error {
+	_, err := i.builtins.Register(name, arity, fn, doc)
+	if err != nil {
+		return err
+	}
+	index, _ := i.builtins.Lookup(name)
+	i.symbolTable.DefineBuiltin(index, name)
+
+	return nil
+}
+
+// Evalúa el código fuente y devuelve el valor de la última expresión.
+// Los globales definidos quedan disponibles para las siguientes llamadas.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// Igual que Eval pero se detiene cuando el contexto termina
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.Program()
+	if len(p.Errors) != 0 {
+		return nil, &ParseError{Messages: p.Errors}
+	}
+
+	comp := compiler.NewWithState(i.symbolTable, i.objectPool)
+	err := comp.Compile(program)
+	// aunque falle, la tabla puede haber guardado consts que apuntan a
+	// literales del pool
+	byteCode := comp.GetByteCode()
+	i.objectPool = byteCode.ObjectPool
+	if err != nil {
+		return nil, err
+	}
+
+	machine := vm.NewWithConfig(byteCode, i.globals, i.config)
+	err = machine.RunContext(ctx)
+	i.globals = machine.Globals()
+	if err != nil {
+		return nil, err
+	}
+
+	// un programa sin expresiones (por ejemplo solo `let`) no deja nada en la pila
+	result := machine.LastPoppedStackElem()
+	if result == nil {
+		return vm.NULL, nil
+	}
+	return result, nil
+}
+
+// Invoca la función global `name` con argumentos Go convertidos a objetos
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// Igual que Call pero se detiene cuando el contexto termina
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	fn, ok := i.GetGlobal(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined function %s", name)
+	}
+
+	objArgs := []object.Object{}
+	for _, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objArgs = append(objArgs, obj)
+	}
+
+	byteCode := &compiler.ByteCode{ObjectPool: i.objectPool}
+	machine := vm.NewWithConfig(byteCode, i.globals, i.config)
+	result, err := machine.CallContext(ctx, fn, objArgs...)
+	i.globals = machine.Globals()
+
+	return result, err
+}
+
+// Define (o redefine) una variable global con un valor Go
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	symbol, ok := i.symbolTable.Resolve(name)
+	if ok && symbol.Constant {
+		// el compilador ya reemplazó sus usos por el literal
+		return fmt.Errorf("cannot assign to constant %s", name)
+	}
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		symbol = i.symbolTable.Define(name)
+	}
+	// el mismo límite que aplica la vm al ejecutar OpSetGlobal
+	limit := i.config.MaxGlobals
+	if limit <= 0 {
+		limit = vm.DefaultConfig().MaxGlobals
+	}
+	if symbol.Index >= limit {
+		return &vm.RuntimeError{Kind: vm.LimitExceeded, Message: fmt.Sprintf("too many globals: limit is %d", limit)}
+	}
+	if symbol.Index >= len(i.globals) {
+		globals := make([]object.Object, symbol.Index+1)
+		copy(globals, i.globals)
+		i.globals = globals
+	}
+	i.globals[symbol.Index] = obj
+
+	return nil
+}
+
+// Devuelve el valor de una variable global o builtin
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	symbol, ok := i.symbolTable.Resolve(name)
+	if !ok {
+		return nil, false
+	}
+
+	switch symbol.Scope {
+	case compiler.GlobalScope:
+		if symbol.Index >= len(i.globals) || i.globals[symbol.Index] == nil {
+			return nil, false
+		}
+		return i.globals[symbol.Index], true
+	case compiler.BuiltinScope:
+		return i.builtins.Get(symbol.Index)
+	}
+	return nil, false
+}
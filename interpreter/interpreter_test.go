@@ -0,0 +1,161 @@
+package interpreter
+
+import (
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/vm"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEvalKeepsGlobals(t *testing.T) {
+	interp := New()
+	if _, err := interp.Eval("let a = 40;"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := interp.Eval("a + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FromObject(result) != int64(42) {
+		t.Errorf("want 42, got=%s", result.Inspect())
+	}
+
+	if _, err := interp.Eval("let = 1"); err == nil {
+		t.Errorf("want a parse error")
+	} else if _, ok := err.(*ParseError); !ok {
+		t.Errorf("want *ParseError, got=%T", err)
+	}
+}
+
+func TestConstantAfterFailedCompile(t *testing.T) {
+	interp := New()
+	// la constante queda definida aunque falle el resto de la entrada
+	if _, err := interp.Eval(`const greeting = "hola"; greeting = 1`); err == nil {
+		t.Fatalf("want a compile error")
+	}
+	result, err := interp.Eval("greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FromObject(result) != "hola" {
+		t.Errorf("want hola, got=%s", result.Inspect())
+	}
+	if err := interp.SetGlobal("greeting", 1); err == nil {
+		t.Errorf("want an error assigning a constant")
+	}
+}
+
+func TestCallAndGlobals(t *testing.T) {
+	interp := New()
+	if err := interp.SetGlobal("config", map[string]interface{}{"factor": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval(`let scale = fn(x) { x * config["factor"] };`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interp.Call("scale", 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FromObject(result) != int64(42) {
+		t.Errorf("want 42, got=%s", result.Inspect())
+	}
+
+	if _, err := interp.Call("missing"); err == nil {
+		t.Errorf("want an error calling an undefined function")
+	}
+
+	value, ok := interp.GetGlobal("config")
+	if !ok {
+		t.Fatalf("config global not found")
+	}
+	if !reflect.DeepEqual(FromObject(value), map[string]interface{}{"factor": int64(3)}) {
+		t.Errorf("wrong config global %s", value.Inspect())
+	}
+}
+
+func TestSetGlobalLimit(t *testing.T) {
+	config := vm.DefaultConfig()
+	config.Builtins = nil
+	config.MaxGlobals = 2
+	interp := NewWithConfig(config)
+
+	for _, name := range []string{"a", "b"} {
+		if err := interp.SetGlobal(name, 1); err != nil {
+			t.Fatalf("SetGlobal(%s): %v", name, err)
+		}
+	}
+	// redefinir un global existente no ocupa un índice nuevo
+	if err := interp.SetGlobal("a", 2); err != nil {
+		t.Errorf("redefining a: %v", err)
+	}
+	err := interp.SetGlobal("c", 3)
+	var runtimeErr *vm.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != vm.LimitExceeded || err.Error() != "too many globals: limit is 2" {
+		t.Errorf("want the globals limit error, got=%v", err)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	interp := New()
+	err := interp.RegisterBuiltin("double", 1, func(ctx *object.ExecContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}, "double(x) returns x * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interp.Eval("double(21)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FromObject(result) != int64(42) {
+		t.Errorf("want 42, got=%s", result.Inspect())
+	}
+
+	// cada intérprete tiene su propio registro
+	if _, err := New().Eval("double(1)"); err == nil {
+		t.Errorf("double must not leak into other interpreters")
+	}
+}
+
+func TestConversions(t *testing.T) {
+	values := []interface{}{
+		nil,
+		true,
+		int64(7),
+		"text",
+		[]interface{}{int64(1), "two", false},
+		map[string]interface{}{"a": int64(1)},
+	}
+	for _, value := range values {
+		obj, err := ToObject(value)
+		if err != nil {
+			t.Fatalf("%v: %s", value, err)
+		}
+		if back := FromObject(obj); !reflect.DeepEqual(back, value) {
+			t.Errorf("round trip of %#v gave %#v", value, back)
+		}
+	}
+
+	// un array que se contiene a sí mismo se corta con nil
+	cycle := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, nil}}
+	cycle.Elements[1] = cycle
+	if back := FromObject(cycle); !reflect.DeepEqual(back, []interface{}{int64(1), nil}) {
+		t.Errorf("wrong conversion of a cyclic array %#v", back)
+	}
+
+	if _, err := ToObject(3.5); err == nil {
+		t.Errorf("want an error converting a float")
+	}
+
+	if obj, err := ToObject(uint64(math.MaxInt64)); err != nil || obj.(*object.Integer).Value != math.MaxInt64 {
+		t.Errorf("want MaxInt64, got=%v (%v)", obj, err)
+	}
+	if _, err := ToObject([]uint64{1, math.MaxInt64 + 1}); err == nil {
+		t.Errorf("want an error converting a uint64 above MaxInt64")
+	}
+}
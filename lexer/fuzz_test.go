@@ -0,0 +1,41 @@
+package lexer
+
+import (
+	"MonkeyHabilis/token"
+	"testing"
+)
+
+// programas representativos para el corpus inicial
+var fuzzSeeds = []string{
+	"",
+	"let x = 5;",
+	"let add = fn(a, b) { a + b }; add(1, 2)",
+	`"unterminated`,
+	"/* unterminated comment",
+	"// only a comment",
+	"if (1) {}",
+	"[]; {}; [1, 2][0]",
+	`{"a": 1}["a"]`,
+	"while (x) { x }",
+	"a && b || !c != -d",
+	"@#$%^~`",
+	"\x00\xff",
+}
+
+// El lexer debe terminar siempre con EOF sin entrar en pánico
+// y consumiendo al menos un caracter por token.
+func FuzzLexer(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+		// cada token consume al menos un caracter
+		for i := 0; i <= len(input); i++ {
+			if l.NextToken().Type == token.EOF {
+				return
+			}
+		}
+		t.Fatalf("lexer did not reach EOF for %q", input)
+	})
+}
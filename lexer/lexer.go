@@ -2,7 +2,6 @@ package lexer
 
 import (
 	"MonkeyHabilis/token"
-	"fmt"
 )
 
 // primero creamos el objeto `Lexer`
@@ -10,13 +9,19 @@ type Lexer struct {
 	input        string
 	pos          int
 	current_char byte
+	line         int // línea de current_char
+	lineStart    int // posición donde empieza esa línea
+	tokenLine    int // posición del token que se está reconociendo
+	tokenColumn  int
 }
 
 // creamos el metodo new para crear un objeto Lexer
 func New(input string) *Lexer {
-	var lexer = &Lexer{input: input, pos: 0}
-	// apuntamos al primer caracter
-	lexer.current_char = lexer.input[lexer.pos]
+	var lexer = &Lexer{input: input, pos: 0, line: 1}
+	// apuntamos al primer caracter (0 si la entrada está vacía)
+	if len(lexer.input) > 0 {
+		lexer.current_char = lexer.input[lexer.pos]
+	}
 
 	return lexer
 }
@@ -28,6 +33,10 @@ func newToken(tType token.Type, tLiteral string) token.Token {
 
 // avanzamos un caracter
 func (l *Lexer) advance() {
+	if l.current_char == '\n' {
+		l.line += 1
+		l.lineStart = l.pos + 1
+	}
 	l.pos += 1
 	if l.pos >= len(l.input) {
 		l.current_char = 0
@@ -125,8 +134,22 @@ func (l *Lexer) getIdentifier() token.Token {
 	return newToken(token.IsKeyword(lexeme), lexeme)
 }
 
-// generamos un Token
+// generamos un Token con la posición donde empieza
 func (l *Lexer) NextToken() token.Token {
+	tok := l.scanToken()
+	tok.Line = l.tokenLine
+	tok.Column = l.tokenColumn
+	return tok
+}
+
+// guarda la posición del caracter actual como inicio del token
+func (l *Lexer) markTokenStart() {
+	l.tokenLine = l.line
+	l.tokenColumn = l.pos - l.lineStart + 1
+}
+
+// reconoce el siguiente token saltando espacios y comentarios
+func (l *Lexer) scanToken() token.Token {
 	for l.current_char != 0 {
 		if isSpace(l.current_char) {
 			l.skipWhitespace()
@@ -140,6 +163,7 @@ func (l *Lexer) NextToken() token.Token {
 			l.skipMultiComment()
 			continue
 		}
+		l.markTokenStart()
 		if isDigit(l.current_char) {
 			return l.getNumber()
 		}
@@ -150,21 +174,9 @@ func (l *Lexer) NextToken() token.Token {
 			return l.getIdentifier()
 		}
 		// caracteres especiales sencillos
-		if l.current_char == '+' {
+		if l.current_char == '%' {
 			l.advance()
-			return newToken(token.PLUS, "+")
-		}
-		if l.current_char == '-' {
-			l.advance()
-			return newToken(token.MINUS, "-")
-		}
-		if l.current_char == '*' {
-			l.advance()
-			return newToken(token.ASTERISK, "*")
-		}
-		if l.current_char == '/' {
-			l.advance()
-			return newToken(token.SLASH, "/")
+			return newToken(token.PERCENT, "%")
 		}
 		if l.current_char == ',' {
 			l.advance()
@@ -207,12 +219,89 @@ func (l *Lexer) NextToken() token.Token {
 			return newToken(token.RBRACKET, "]")
 		}
 		// caracteres especiales compuestos
+		if l.current_char == '+' {
+			l.advance()
+			if l.current_char == '=' {
+				l.advance()
+				return newToken(token.PLUS_ASSIGN, "+=")
+			}
+			if l.current_char == '+' {
+				l.advance()
+				return newToken(token.INCREMENT, "++")
+			}
+			return newToken(token.PLUS, "+")
+		}
+		if l.current_char == '-' {
+			l.advance()
+			if l.current_char == '=' {
+				l.advance()
+				return newToken(token.MINUS_ASSIGN, "-=")
+			}
+			if l.current_char == '-' {
+				l.advance()
+				return newToken(token.DECREMENT, "--")
+			}
+			return newToken(token.MINUS, "-")
+		}
+		if l.current_char == '*' {
+			l.advance()
+			if l.current_char == '*' {
+				l.advance()
+				return newToken(token.POWER, "**")
+			}
+			if l.current_char == '=' {
+				l.advance()
+				return newToken(token.ASTERISK_ASSIGN, "*=")
+			}
+			return newToken(token.ASTERISK, "*")
+		}
+		// los comentarios ya se descartaron arriba
+		if l.current_char == '/' {
+			l.advance()
+			if l.current_char == '=' {
+				l.advance()
+				return newToken(token.SLASH_ASSIGN, "/=")
+			}
+			return newToken(token.SLASH, "/")
+		}
+		if l.current_char == '~' {
+			l.advance()
+			if l.current_char == '/' {
+				l.advance()
+				return newToken(token.FLOORDIV, "~/")
+			}
+			return newToken(token.BIT_NOT, "~")
+		}
+		if l.current_char == '?' {
+			l.advance()
+			switch l.current_char {
+			case '?':
+				l.advance()
+				return newToken(token.NULLISH, "??")
+			case '.':
+				l.advance()
+				return newToken(token.OPTIONAL_DOT, "?.")
+			case '[':
+				// `c ? [1] : [2]` necesita el espacio para no leerse como `?[`
+				l.advance()
+				return newToken(token.OPTIONAL_LBRACKET, "?[")
+			}
+			return newToken(token.QUESTION, "?")
+		}
+		if l.current_char == '^' {
+			l.advance()
+			return newToken(token.BIT_XOR, "^")
+		}
 		if l.current_char == '<' {
 			l.advance()
 			if l.current_char == '=' {
 				l.advance()
 				return newToken(token.LT_EQ, "<=")
 			}
+			if l.current_char == '<' {
+				l.advance()
+				return newToken(token.SHIFT_LEFT, "<<")
+			}
 			return newToken(token.LT, "<")
 		}
 		if l.current_char == '>' {
@@ -221,6 +310,10 @@ func (l *Lexer) NextToken() token.Token {
 				l.advance()
 				return newToken(token.GT_EQ, ">=")
 			}
+			if l.current_char == '>' {
+				l.advance()
+				return newToken(token.SHIFT_RIGHT, ">>")
+			}
 			return newToken(token.GT, ">")
 		}
 		if l.current_char == '!' {
@@ -237,19 +330,37 @@ func (l *Lexer) NextToken() token.Token {
 				l.advance()
 				return newToken(token.EQ, "==")
 			}
+			if l.current_char == '>' {
+				l.advance()
+				return newToken(token.ARROW, "=>")
+			}
 			return newToken(token.ASSIGN, "=")
 		}
-		if l.current_char == '&' && l.peek() == '&' {
-			l.advance()
+		if l.current_char == '&' {
 			l.advance()
-			return newToken(token.AND, "&&")
+			if l.current_char == '&' {
+				l.advance()
+				return newToken(token.AND, "&&")
+			}
+			return newToken(token.BIT_AND, "&")
 		}
-		if l.current_char == '|' && l.peek() == '|' {
-			l.advance()
+		if l.current_char == '|' {
 			l.advance()
-			return newToken(token.OR, "||")
+			if l.current_char == '|' {
+				l.advance()
+				return newToken(token.OR, "||")
+			}
+			if l.current_char == '>' {
+				l.advance()
+				return newToken(token.PIPE, "|>")
+			}
+			return newToken(token.BIT_OR, "|")
 		}
-		fmt.Printf("unknown character: %c\n", l.current_char)
+		// caracter desconocido: lo devolvemos como ILLEGAL para que el parser lo reporte
+		illegal := l.current_char
+		l.advance()
+		return newToken(token.ILLEGAL, string(illegal))
 	}
+	l.markTokenStart()
 	return newToken(token.EOF, "")
 }
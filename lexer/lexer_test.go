@@ -0,0 +1,280 @@
+package lexer
+
+import (
+	"MonkeyHabilis/token"
+	"testing"
+)
+
+func TestNextToken(t *testing.T) {
+	input := `let five = 5;
+let add = fn(x, y) { x + y; };
+!-/ *5;
+7 % 2 ** 3 ~/ 1;
+5 < 10 > 5 <= 10 >= 5;
+10 == 10; 10 != 9;
+true && false || null;
+a & b | c ^ ~d << 1 >> 2 <= 3;
+a += 1; a -= 1; a *= 2; a /= 2; a++; a--;
+c ? [x] : y ?? h?.k?[0];
+a |> f() | b;
+(x, y) => x == y;
+if (5 < 10) { return true; } else { return false; }
+while (x) { x }
+"foo bar" 'single'
+[1, 2]; {"key": "value"}; a.b
+// comentario de una línea
+/* comentario
+   multilínea */
+_under_score1 @`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "five"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "add"},
+		{token.ASSIGN, "="},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.BANG, "!"},
+		{token.MINUS, "-"},
+		{token.SLASH, "/"},
+		{token.ASTERISK, "*"},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "7"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.FLOORDIV, "~/"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "5"},
+		{token.LT, "<"},
+		{token.INT, "10"},
+		{token.GT, ">"},
+		{token.INT, "5"},
+		{token.LT_EQ, "<="},
+		{token.INT, "10"},
+		{token.GT_EQ, ">="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.EQ, "=="},
+		{token.INT, "10"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.NOT_EQ, "!="},
+		{token.INT, "9"},
+		{token.SEMICOLON, ";"},
+		{token.TRUE, "true"},
+		{token.AND, "&&"},
+		{token.FALSE, "false"},
+		{token.OR, "||"},
+		{token.NULL, "null"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "b"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "c"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "1"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "2"},
+		{token.LT_EQ, "<="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.INCREMENT, "++"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.DECREMENT, "--"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "c"},
+		{token.QUESTION, "?"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "x"},
+		{token.RBRACKET, "]"},
+		{token.COLON, ":"},
+		{token.IDENT, "y"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "h"},
+		{token.OPTIONAL_DOT, "?."},
+		{token.IDENT, "k"},
+		{token.OPTIONAL_LBRACKET, "?["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "x"},
+		{token.EQ, "=="},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.IF, "if"},
+		{token.LPAREN, "("},
+		{token.INT, "5"},
+		{token.LT, "<"},
+		{token.INT, "10"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RETURN, "return"},
+		{token.TRUE, "true"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.ELSE, "else"},
+		{token.LBRACE, "{"},
+		{token.RETURN, "return"},
+		{token.FALSE, "false"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.STRING, "foo bar"},
+		{token.STRING, "single"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "key"},
+		{token.COLON, ":"},
+		{token.STRING, "value"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.IDENT, "_under_score1"},
+		{token.ILLEGAL, "@"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q (%q)", i, tt.expectedType, tok.Type, tok.Literal)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  // nota\n  x = \"a\nb\" + 1"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1}, {"x", 1, 5}, {"=", 1, 7}, {"5", 1, 9}, {";", 1, 10},
+		{"x", 3, 3}, {"=", 3, 5}, {"a\nb", 3, 7},
+		// el string ocupa dos líneas
+		{"+", 4, 4}, {"1", 4, 6}, {"", 4, 7},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral || tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - want %q at %d:%d, got %q at %d:%d", i, tt.expectedLiteral, tt.expectedLine, tt.expectedColumn, tok.Literal, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	for literal, expected := range token.Keywords {
+		tok := New(literal).NextToken()
+		if tok.Type != expected {
+			t.Errorf("keyword %q: expected=%q, got=%q", literal, expected, tok.Type)
+		}
+	}
+	if tokenType := token.IsKeyword("fnx"); tokenType != token.IDENT {
+		t.Errorf("identifier fnx: expected=%q, got=%q", token.IDENT, tokenType)
+	}
+}
+
+// el lexer siempre agrupa `--`; el parser decide si es un decremento
+func TestMinusSequences(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Type
+	}{
+		{"a--b", []token.Type{token.IDENT, token.DECREMENT, token.IDENT}},
+		{"5--3", []token.Type{token.INT, token.DECREMENT, token.INT}},
+		{"- -x", []token.Type{token.MINUS, token.MINUS, token.IDENT}},
+		{"a---b", []token.Type{token.IDENT, token.DECREMENT, token.MINUS, token.IDENT}},
+		{"a-=-1", []token.Type{token.IDENT, token.MINUS_ASSIGN, token.MINUS, token.INT}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range append(tt.expected, token.EOF) {
+			tok := l.NextToken()
+			if tok.Type != expected {
+				t.Fatalf("%q: tokens[%d] - expected=%q, got=%q", tt.input, i, expected, tok.Type)
+			}
+		}
+	}
+}
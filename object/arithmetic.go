@@ -0,0 +1,57 @@
+package object
+
+import "math"
+
+// División entera redondeando hacia menos infinito: -7 ~/ 2 es -4.
+// El divisor no puede ser cero.
+func FloorDiv(a int64, b int64) int64 {
+	quotient := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		quotient -= 1
+	}
+	return quotient
+}
+
+// Residuo con el signo del divisor, de modo que
+// a == FloorDiv(a, b) * b + FloorMod(a, b). El divisor no puede ser cero.
+func FloorMod(a int64, b int64) int64 {
+	remainder := a % b
+	if remainder != 0 && (remainder < 0) != (b < 0) {
+		remainder += b
+	}
+	return remainder
+}
+
+// Calcula base ** exp por cuadrados sucesivos. El exponente no puede ser
+// negativo; devuelve false si el resultado no cabe en un int64.
+func Power(base int64, exp int64) (int64, bool) {
+	result := int64(1)
+	ok := true
+	for exp > 0 {
+		if exp&1 == 1 {
+			if result, ok = multiply(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		// solo elevamos al cuadrado si todavía se va a usar
+		if exp > 0 {
+			if base, ok = multiply(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// multiplica detectando el desbordamiento
+func multiply(a int64, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	product := a * b
+	return product, product/b == a
+}
@@ -0,0 +1,59 @@
+package object
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloorDivAndMod(t *testing.T) {
+	tests := []struct {
+		a, b          int64
+		quotient, mod int64
+	}{
+		{7, 2, 3, 1},
+		{-7, 2, -4, 1},
+		{7, -2, -4, -1},
+		{-7, -2, 3, -1},
+		{-8, 2, -4, 0},
+		{0, -3, 0, 0},
+		{math.MinInt64, 1, math.MinInt64, 0},
+	}
+
+	for _, tt := range tests {
+		quotient, mod := FloorDiv(tt.a, tt.b), FloorMod(tt.a, tt.b)
+		if quotient != tt.quotient || mod != tt.mod {
+			t.Errorf("%d, %d: want=(%d, %d), got=(%d, %d)", tt.a, tt.b, tt.quotient, tt.mod, quotient, mod)
+		}
+		if quotient*tt.b+mod != tt.a {
+			t.Errorf("%d, %d: quotient and remainder do not add up", tt.a, tt.b)
+		}
+	}
+}
+
+func TestPower(t *testing.T) {
+	tests := []struct {
+		base, exp int64
+		expected  int64
+		ok        bool
+	}{
+		{2, 10, 1024, true},
+		{-3, 3, -27, true},
+		{5, 0, 1, true},
+		{0, 0, 1, true},
+		{1, math.MaxInt64, 1, true},
+		{-1, math.MaxInt64, -1, true},
+		{-2, 63, math.MinInt64, true},
+		{2, 62, 1 << 62, true},
+		{2, 63, 0, false},
+		{3, 40, 0, false},
+		{math.MinInt64, 2, 0, false},
+		{math.MinInt64, 1, math.MinInt64, true},
+	}
+
+	for _, tt := range tests {
+		value, ok := Power(tt.base, tt.exp)
+		if value != tt.expected || ok != tt.ok {
+			t.Errorf("%d ** %d: want=(%d, %t), got=(%d, %t)", tt.base, tt.exp, tt.expected, tt.ok, value, ok)
+		}
+	}
+}
@@ -2,132 +2,189 @@ package object
 
 import (
 	"fmt"
+	"io"
+	"strings"
 )
 
-var Builtins = []struct {
-	Name    string
-	Builtin *Builtin
-}{
+// Lista de builtins estándar. Cada BuiltinRegistry parte de una copia
+// de esta lista y luego el programa anfitrión puede agregar los suyos.
+// La vm y el evaluador comprueban Arity antes de llamar a Fn.
+var builtins = []Builtin{
 	{
-		"len",
-		&Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				switch arg := args[0].(type) {
-				case *Array:
-					return &Integer{Value: int64(len(arg.Elements))}
-				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
-				default:
-					return newError("argument to `len` not supported, got %s", args[0].Type())
-				}
-			},
+		Name:  "len",
+		Arity: 1,
+		Doc:   "len(x) returns the length of an array, string or range",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			switch arg := args[0].(type) {
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *String:
+				return &Integer{Value: int64(len(arg.Value))}
+			case *Range:
+				return &Integer{Value: arg.Len()}
+			default:
+				return newError("argument to `len` not supported, got %s", args[0].Type())
+			}
 		},
 	},
 	{
-		"puts",
-		&Builtin{
-			Fn: func(args ...Object) Object {
-				for _, arg := range args {
-					fmt.Println(arg.Inspect())
-				}
-				return nil
-			},
+		Name:  "puts",
+		Arity: VARIADIC,
+		Doc:   "puts(args...) prints each argument on its own line",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			for _, arg := range args {
+				fmt.Fprintln(ctx.Out, arg.Inspect())
+			}
+			return nil
 		},
 	},
 	{
-		"first",
-		&Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-				}
-				arr := args[0].(*Array)
-				if len(arr.Elements) > 0 {
-					return arr.Elements[0]
-				}
+		Name:  "first",
+		Arity: 1,
+		Doc:   "first(arr) returns the first element of the array",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
 
-				return nil
-			},
+			return nil
 		},
 	},
 	{
-		"last",
-		&Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-				}
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					return arr.Elements[length-1]
-				}
-				return nil
-			},
+		Name:  "last",
+		Arity: 1,
+		Doc:   "last(arr) returns the last element of the array",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return nil
 		},
 	},
 	{
-		"rest",
-		&Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 1 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-				}
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
-				if length > 0 {
-					newElements := make([]Object, length-1, length-1)
-					copy(newElements, arr.Elements[1:length])
-					return &Array{Elements: newElements}
-				}
-				return nil
-			},
+		Name:  "rest",
+		Arity: 1,
+		Doc:   "rest(arr) returns a new array without the first element",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{Elements: newElements}
+			}
+			return nil
 		},
 	},
 	{
-		"push",
-		&Builtin{
-			Fn: func(args ...Object) Object {
-				if len(args) != 2 {
-					return newError("wrong number of arguments. got=%d, want=2", len(args))
-				}
-				if args[0].Type() != ARRAY_OBJ {
-					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-				}
-				arr := args[0].(*Array)
-				length := len(arr.Elements)
+		Name:  "push",
+		Arity: 2,
+		Doc:   "push(arr, x) returns a new array with x appended",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
 
-				newElements := make([]Object, length+1, length+1)
-				copy(newElements, arr.Elements)
-				newElements[length] = args[1]
+			newElements := make([]Object, length+1, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
 
-				return &Array{Elements: newElements}
-			},
+			return &Array{Elements: newElements}
+		},
+	},
+	{
+		Name:  "range",
+		Arity: VARIADIC,
+		Doc:   "range(end), range(start, end) or range(start, end, step) returns the integers from start up to end (exclusive) for use in for-in loops",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+			}
+			values := []int64{}
+			for _, arg := range args {
+				integer, ok := arg.(*Integer)
+				if !ok {
+					return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+				}
+				values = append(values, integer.Value)
+			}
+			rangeObj := &Range{Start: 0, Step: 1}
+			switch len(values) {
+			case 1:
+				rangeObj.End = values[0]
+			case 2:
+				rangeObj.Start, rangeObj.End = values[0], values[1]
+			case 3:
+				rangeObj.Start, rangeObj.End, rangeObj.Step = values[0], values[1], values[2]
+			}
+			if rangeObj.Step == 0 {
+				return newError("range step can't be zero")
+			}
+			return rangeObj
+		},
+	},
+	{
+		Name:  "print",
+		Arity: VARIADIC,
+		Doc:   "print(args...) prints the arguments separated by spaces without a trailing newline",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			values := []string{}
+			for _, arg := range args {
+				values = append(values, arg.Inspect())
+			}
+			io.WriteString(ctx.Out, strings.Join(values, " "))
+			return nil
+		},
+	},
+	{
+		Name:  "input",
+		Arity: VARIADIC,
+		Doc:   "input(prompt?) prints the optional prompt and reads a line; returns null at end of input",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 1 {
+				io.WriteString(ctx.Out, args[0].Inspect())
+			}
+			return readLine(ctx)
+		},
+	},
+	{
+		Name:  "readline",
+		Arity: 0,
+		Doc:   "readline() reads a line from the input; returns null at end of input",
+		Fn: func(ctx *ExecContext, args ...Object) Object {
+			return readLine(ctx)
 		},
 	},
 }
 
-func newError(format string, a ...interface{}) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+// lee una línea del flujo de entrada sin el salto de línea final
+func readLine(ctx *ExecContext) Object {
+	line, err := ctx.In.ReadString('\n')
+	if err != nil && line == "" {
+		return nil
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return &String{Value: line}
 }
 
-func GetBuiltinByName(name string) *Builtin {
-	for _, def := range Builtins {
-		if def.Name == name {
-			return def.Builtin
-		}
-	}
-	return nil
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
 }
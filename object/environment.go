@@ -1,30 +1,184 @@
 package object
 
+import "fmt"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.local = outer.local
 	return env
 }
 
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
+	s := make(map[string]*Cell)
 	return &Environment{store: s, outer: nil}
 }
 
+// Crea el entorno raíz con los builtins. Un let puede ocultarlos pero
+// no se les puede asignar.
+func NewBuiltinEnvironment(builtins []*Builtin) *Environment {
+	env := NewEnvironment()
+	for _, builtin := range builtins {
+		env.Set(builtin.Name, builtin)
+	}
+	env.builtins = true
+	return env
+}
+
+// Crea el entorno de un bloque de if o de ciclo. Sus variables son
+// locales aunque el bloque esté en el nivel superior, igual que en la vm.
+func NewBlockEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.local = true
+	return env
+}
+
+// Crea el entorno de una llamada. Sus variables, y las de los bloques
+// que encierre, son locales a la función igual que en la vm.
+func NewFunctionEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.local = true
+	return env
+}
+
 type Environment struct {
-	store map[string]Object
+	// cada variable vive en una celda que las clausuras comparten
+	store map[string]*Cell
 	outer *Environment
+	// local indica que el entorno pertenece a una llamada a función
+	local bool
+	// nombres con los que las funciones que encierran el entorno se
+	// llaman a sí mismas
+	functions map[string]bool
+	// builtins indica que es el entorno raíz de los builtins
+	builtins bool
+	// nombres definidos con const en este entorno
+	constants map[string]bool
+}
+
+// indica si el nombre está definido en este mismo entorno
+func (e *Environment) has(name string) bool {
+	_, ok := e.store[name]
+	return ok
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	if e.has(name) {
+		return e.store[name].Value, true
+	}
+	if e.outer != nil {
+		return e.outer.Get(name)
 	}
-	return obj, ok
+	return nil, false
 }
 
+// Define una variable en este entorno. Un let crea siempre una celda
+// nueva: las clausuras creadas antes siguen viendo la anterior.
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	e.store[name] = &Cell{Value: val}
 	return val
 }
+
+// Define una constante en este entorno.
+func (e *Environment) SetConstant(name string, val Object) Object {
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
+	return e.Set(name, val)
+}
+
+// Indica si este mismo entorno define el nombre como constante. Igual que
+// en el compilador, un let en un ámbito interior sí puede ocultarla.
+func (e *Environment) DefinesConstant(name string) bool {
+	return e.has(name) && e.constants[name]
+}
+
+// Indica si la variable visible con ese nombre es una constante.
+func (e *Environment) IsConstant(name string) bool {
+	for env := e; env != nil; env = env.outer {
+		if env.has(name) {
+			return env.constants[name]
+		}
+	}
+	return false
+}
+
+// Define el nombre con el que una función se llama a sí misma. Igual que
+// en la vm, ese nombre no admite asignación.
+func (e *Environment) SetSelf(name string, fn Object) Object {
+	e.markFunction(name)
+	return e.Set(name, fn)
+}
+
+func (e *Environment) markFunction(name string) {
+	if e.functions == nil {
+		e.functions = make(map[string]bool)
+	}
+	e.functions[name] = true
+}
+
+// Cambia el valor de una variable en el entorno donde fue definida.
+func (e *Environment) Assign(name string, val Object) error {
+	env, err := e.assignable(name)
+	if err != nil {
+		return err
+	}
+	env.store[name].Value = val
+	return nil
+}
+
+// Indica con el mismo error que el compilador si se puede asignar la
+// variable, sin modificarla.
+func (e *Environment) CanAssign(name string) error {
+	_, err := e.assignable(name)
+	return err
+}
+
+// busca el entorno donde fue definida la variable. Igual que el
+// compilador, rechaza los builtins y el nombre de la propia función.
+func (e *Environment) assignable(name string) (*Environment, error) {
+	for env := e; env != nil; env = env.outer {
+		if !env.has(name) {
+			continue
+		}
+		if env.builtins {
+			return nil, fmt.Errorf("cannot assign to builtin %s", name)
+		}
+		if env.functions[name] {
+			return nil, fmt.Errorf("cannot assign to function %s", name)
+		}
+		return env, nil
+	}
+	return nil, fmt.Errorf("undefined variable %s", name)
+}
+
+// Reúne las celdas de las variables locales visibles para una clausura.
+// Igual que en la vm, la clausura comparte las celdas que existen al
+// crearla, así que ve las asignaciones pero no los let posteriores. Las
+// globales se siguen buscando por nombre, así que el nuevo entorno apunta
+// al primer entorno no local.
+func (e *Environment) Capture() *Environment {
+	env := e
+	captured := NewEnvironment()
+	for ; env != nil && env.local; env = env.outer {
+		for name, cell := range env.store {
+			if _, ok := captured.store[name]; ok {
+				continue
+			}
+			captured.store[name] = cell
+			if env.constants[name] {
+				if captured.constants == nil {
+					captured.constants = make(map[string]bool)
+				}
+				captured.constants[name] = true
+			}
+			if env.functions[name] {
+				captured.markFunction(name)
+			}
+		}
+	}
+	captured.outer = env
+	captured.local = true
+	return captured
+}
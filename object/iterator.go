@@ -0,0 +1,152 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Iterable lo implementan los objetos que se pueden recorrer con for-in
+type Iterable interface {
+	Object
+	Iterator() Iterator
+}
+
+// Iterator recorre una colección sin copiarla. Next devuelve la clave
+// (índice o clave del hash) y el valor de cada elemento, y ok=false
+// cuando ya no quedan elementos. También es un Object para que la vm
+// lo pueda guardar en la pila mientras dura el ciclo.
+type Iterator interface {
+	Object
+	Next() (key Object, value Object, ok bool)
+}
+
+// parte común de los iteradores
+type iteratorObject struct{}
+
+func (it *iteratorObject) Type() ObjectType { return ITERATOR_OBJ }
+func (it *iteratorObject) Inspect() string  { return "iterator" }
+
+// recorre los elementos del array; si el array crece durante
+// el ciclo también se visitan los nuevos elementos.
+type arrayIterator struct {
+	iteratorObject
+	array *Array
+	index int
+}
+
+func (ao *Array) Iterator() Iterator {
+	return &arrayIterator{array: ao}
+}
+
+func (it *arrayIterator) Next() (Object, Object, bool) {
+	if it.index >= len(it.array.Elements) {
+		return nil, nil, false
+	}
+	key := &Integer{Value: int64(it.index)}
+	value := it.array.Elements[it.index]
+	it.index += 1
+	return key, value, true
+}
+
+// recorre las claves del hash en orden alfabético. Solo se copian las
+// claves al empezar, los valores se leen del hash en cada paso.
+type hashIterator struct {
+	iteratorObject
+	hash  *Hash
+	keys  []string
+	index int
+}
+
+func (h *Hash) Iterator() Iterator {
+	keys := make([]string, 0, len(h.Pairs))
+	for key := range h.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &hashIterator{hash: h, keys: keys}
+}
+
+func (it *hashIterator) Next() (Object, Object, bool) {
+	for it.index < len(it.keys) {
+		key := it.keys[it.index]
+		it.index += 1
+		if value, ok := it.hash.Pairs[key]; ok {
+			return &String{Value: key}, value, true
+		}
+	}
+	return nil, nil, false
+}
+
+// recorre los caracteres (bytes) del string, igual que el acceso por índice
+type stringIterator struct {
+	iteratorObject
+	str   *String
+	index int
+}
+
+func (s *String) Iterator() Iterator {
+	return &stringIterator{str: s}
+}
+
+func (it *stringIterator) Next() (Object, Object, bool) {
+	if it.index >= len(it.str.Value) {
+		return nil, nil, false
+	}
+	key := &Integer{Value: int64(it.index)}
+	value := &String{Value: string(it.str.Value[it.index])}
+	it.index += 1
+	return key, value, true
+}
+
+// Range representa los enteros desde Start hasta End (sin incluirlo)
+// avanzando de Step en Step. No reserva memoria para los elementos.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// número de elementos del rango. Se calcula sin signo para que los
+// rangos que cubren casi todo int64 no desborden.
+func (r *Range) Len() int64 {
+	var distance, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		distance, step = uint64(r.End-r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.End:
+		distance, step = uint64(r.Start-r.End), uint64(-r.Step)
+	default:
+		return 0
+	}
+	length := (distance-1)/step + 1
+	if length > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(length)
+}
+
+type rangeIterator struct {
+	iteratorObject
+	rangeObj *Range
+	index    int64
+}
+
+func (r *Range) Iterator() Iterator {
+	return &rangeIterator{rangeObj: r}
+}
+
+func (it *rangeIterator) Next() (Object, Object, bool) {
+	if it.index >= it.rangeObj.Len() {
+		return nil, nil, false
+	}
+	key := &Integer{Value: it.index}
+	value := &Integer{Value: it.rangeObj.Start + it.index*it.rangeObj.Step}
+	it.index += 1
+	return key, value, true
+}
@@ -1,15 +1,24 @@
 package object
 
 import (
-	"CornyLang/ast"
+	"MonkeyHabilis/ast"
 	"MonkeyHabilis/code"
+	"bufio"
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"strings"
 )
 
-type BuiltinFunction func(args ...Object) Object
+// Contexto de ejecución que la vm entrega a cada builtin con
+// los flujos de entrada y salida configurados para esa vm.
+type ExecContext struct {
+	Out io.Writer
+	In  *bufio.Reader
+}
+
+type BuiltinFunction func(ctx *ExecContext, args ...Object) Object
 
 type ObjectType string
 
@@ -26,6 +35,9 @@ const (
 	HASH_OBJ              = "HASH"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 	CLOSURE_OBJ           = "CLOSURE"
+	RANGE_OBJ             = "RANGE"
+	ITERATOR_OBJ          = "ITERATOR"
+	CELL_OBJ              = "CELL"
 )
 
 type HashKey struct {
@@ -118,6 +130,9 @@ type CompiledFunction struct {
 	Instructions  []code.Instruction
 	NumLocals     int // número de variables locales
 	NumParameters int // número de parámetros que define.
+	// parámetros capturados por alguna closure: la vm los guarda en una
+	// celda al llamar a la función
+	CellParameters []int
 	/**************************INICIO DEBUG************************/
 	StrByteCode string
 	/**************************FIN DEBUG***************************/
@@ -142,6 +157,16 @@ func (c *Closure) Inspect() string {
 	return c.Fn.StrByteCode
 }
 
+// Celda con el valor de una variable local capturada por una closure. La
+// función y sus closures comparten la celda, así que todas ven las
+// asignaciones de las demás.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string  { return c.Value.Inspect() }
+
 type String struct {
 	Value string
 }
@@ -156,23 +181,56 @@ func (s *String) HashKey() HashKey {
 }
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Name  string
+	Arity int    // número de argumentos o VARIADIC
+	Doc   string // descripción para la ayuda
+	Fn    BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+// Los arrays y hashes se comparten por referencia: asignar a un índice
+// modifica el objeto que ven todas las variables que lo apuntan.
+// Los slices y los builtins como push y rest devuelven copias.
 type Array struct {
 	Elements []Object
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
 func (ao *Array) Inspect() string {
+	return inspectNested(ao, map[Object]bool{})
+}
+
+// Como los arrays y hashes se pueden modificar, pueden contenerse a sí mismos
+// (a[0] = a). `seen` guarda las colecciones que se están imprimiendo para
+// mostrar [...] o {...} en lugar de recorrerlas de nuevo.
+func inspectNested(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+		return obj.inspect(seen)
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+		return obj.inspect(seen)
+	}
+	return obj.Inspect()
+}
+
+func (ao *Array) inspect(seen map[Object]bool) string {
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspectNested(e, seen))
 	}
 
 	out.WriteString("[")
@@ -188,11 +246,15 @@ type Hash struct {
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
+	return inspectNested(h, map[Object]bool{})
+}
+
+func (h *Hash) inspect(seen map[Object]bool) string {
 	var out bytes.Buffer
 
 	pairs := []string{}
 	for key, value := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", key, value.Inspect()))
+		pairs = append(pairs, fmt.Sprintf("%s: %s", key, inspectNested(value, seen)))
 	}
 
 	out.WriteString("{")
@@ -0,0 +1,77 @@
+package object
+
+import (
+	"fmt"
+)
+
+// acepta cualquier número de argumentos
+const VARIADIC = -1
+
+// BuiltinRegistry guarda los builtins disponibles para un par
+// compilador/máquina virtual. El compilador asigna a cada nombre
+// su índice en el registro y la vm lo usa para OpGetBuiltin.
+type BuiltinRegistry struct {
+	builtins []*Builtin
+	names    map[string]int
+}
+
+// Crea un registro con los builtins estándar
+func NewBuiltinRegistry() *BuiltinRegistry {
+	registry := &BuiltinRegistry{
+		builtins: []*Builtin{},
+		names:    make(map[string]int),
+	}
+	// cada registro tiene sus propias copias para que no se compartan
+	for _, builtin := range builtins {
+		builtin := builtin
+		registry.add(&builtin)
+	}
+	return registry
+}
+
+// agrega el builtin al final del registro
+func (r *BuiltinRegistry) add(builtin *Builtin) {
+	r.names[builtin.Name] = len(r.builtins)
+	r.builtins = append(r.builtins, builtin)
+}
+
+// Registra una función del programa anfitrión.
+// `arity` es el número de argumentos esperado o VARIADIC.
+func (r *BuiltinRegistry) Register(name string, arity int, fn BuiltinFunction, doc string) (*Builtin, error) {
+	if name == "" {
+		return nil, fmt.Errorf("builtin name can't be empty")
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("builtin %s has no function", name)
+	}
+	if arity < VARIADIC {
+		return nil, fmt.Errorf("invalid arity %d for builtin %s", arity, name)
+	}
+	if _, ok := r.names[name]; ok {
+		return nil, fmt.Errorf("builtin %s already registered", name)
+	}
+
+	builtin := &Builtin{Name: name, Arity: arity, Doc: doc, Fn: fn}
+	r.add(builtin)
+
+	return builtin, nil
+}
+
+// Devuelve el builtin que ocupa el índice indicado
+func (r *BuiltinRegistry) Get(index int) (*Builtin, bool) {
+	if index < 0 || index >= len(r.builtins) {
+		return nil, false
+	}
+	return r.builtins[index], true
+}
+
+// Devuelve el índice del builtin con el nombre indicado
+func (r *BuiltinRegistry) Lookup(name string) (int, bool) {
+	index, ok := r.names[name]
+	return index, ok
+}
+
+// Devuelve todos los builtins en orden de índice
+func (r *BuiltinRegistry) All() []*Builtin {
+	return r.builtins
+}
@@ -0,0 +1,30 @@
+package object
+
+import "testing"
+
+func TestRegistriesAreIsolated(t *testing.T) {
+	a := NewBuiltinRegistry()
+	b := NewBuiltinRegistry()
+
+	index, ok := a.Lookup("len")
+	if !ok {
+		t.Fatalf("len not registered")
+	}
+	lenA, _ := a.Get(index)
+	lenB, _ := b.Get(index)
+	if lenA == lenB {
+		t.Fatalf("registries share the same *Builtin")
+	}
+
+	// modificar un registro no afecta a los demás
+	lenA.Doc = "changed"
+	if lenB.Doc == "changed" {
+		t.Errorf("doc change leaked to another registry")
+	}
+	if _, err := a.Register("host", 0, func(ctx *ExecContext, args ...Object) Object { return nil }, ""); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, ok := b.Lookup("host"); ok {
+		t.Errorf("host builtin leaked to another registry")
+	}
+}
@@ -0,0 +1,51 @@
+package object
+
+// tamaños aproximados (en bytes) usados para contabilizar memoria.
+// No pretenden ser exactos, solo proporcionales a lo que ocupa cada objeto.
+const (
+	OBJECT_HEADER_SIZE = 16 // interfaz + puntero al objeto
+	STRING_SIZE        = 16 // cabecera de un string
+	SLICE_SIZE         = 24 // cabecera de un slice
+	MAP_SIZE           = 48 // cabecera de un map
+	MAP_ENTRY_SIZE     = 16 // sobrecoste de cada entrada de un map
+)
+
+// Tamaño de un string con `length` bytes
+func StringSize(length int) int64 {
+	return int64(STRING_SIZE + length)
+}
+
+// Tamaño de un array con `length` elementos (sin contar los elementos)
+func ArraySize(length int) int64 {
+	return int64(SLICE_SIZE + OBJECT_HEADER_SIZE*length)
+}
+
+// Tamaño de una entrada de un hash con la clave indicada
+func HashEntrySize(key string) int64 {
+	return int64(MAP_ENTRY_SIZE+OBJECT_HEADER_SIZE) + StringSize(len(key))
+}
+
+// Tamaño de un closure con `numFree` variables libres
+func ClosureSize(numFree int) int64 {
+	return int64(OBJECT_HEADER_SIZE + SLICE_SIZE + OBJECT_HEADER_SIZE*numFree)
+}
+
+// Tamaño aproximado de un objeto recién creado. Los elementos de arrays
+// y hashes no se cuentan porque ya fueron contabilizados al crearlos.
+func SizeOf(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return StringSize(len(obj.Value))
+	case *Array:
+		return ArraySize(len(obj.Elements))
+	case *Hash:
+		size := int64(MAP_SIZE)
+		for key := range obj.Pairs {
+			size += HashEntrySize(key)
+		}
+		return size
+	case *Closure:
+		return ClosureSize(len(obj.Free))
+	}
+	return OBJECT_HEADER_SIZE
+}
@@ -0,0 +1,44 @@
+package object
+
+import "fmt"
+
+// Calcula los extremos de un slice sobre una secuencia de `length` elementos.
+// `start` y `end` son null cuando se omitieron, los negativos cuentan desde
+// el final y ambos se ajustan a los límites de la secuencia.
+func SliceBounds(length int, start Object, end Object) (int, int, error) {
+	low, err := sliceIndex(start, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := sliceIndex(end, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	// un rango invertido da una secuencia vacía
+	if low > high {
+		low = high
+	}
+	return low, high, nil
+}
+
+// convierte un extremo del slice en un índice dentro de [0, length]
+func sliceIndex(obj Object, missing int, length int) (int, error) {
+	if _, ok := obj.(*Null); ok {
+		return missing, nil
+	}
+	integer, ok := obj.(*Integer)
+	if !ok {
+		return 0, fmt.Errorf("invalid slice index data type %s", obj.Type())
+	}
+	index := integer.Value
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 {
+		return 0, nil
+	}
+	if index > int64(length) {
+		return length, nil
+	}
+	return int(index), nil
+}
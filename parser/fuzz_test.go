@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"MonkeyHabilis/lexer"
+	"testing"
+)
+
+// programas representativos para el corpus inicial
+var fuzzSeeds = []string{
+	"",
+	"let x = 5;",
+	"let add = fn(a, b) { a + b }; add(1, 2)",
+	"/* unterminated comment",
+	"if (1) {}",
+	"if (x) { 1 } else { 2 }",
+	"[]; {}; [1, 2][0]",
+	`{"a": 1, "b": [true, null]}["a"]`,
+	"while (x) { x }",
+	"fn(x { x }",
+	"fn(1) { }",
+	"{ { {",
+	") ] }",
+	"let = ;",
+	"return",
+	"a.b.c(1)[2]",
+	"a[1:][:2]; a[:]",
+	"a[0] = b[1] = c; x = 1",
+	"for (k, v in h) { for (x in v) { x } }",
+}
+
+// El parser debe terminar sin entrar en pánico con cualquier entrada;
+// los errores de sintaxis se reportan en p.Errors.
+func FuzzParser(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.Program()
+		if program == nil {
+			t.Fatalf("nil program for %q", input)
+		}
+		if len(p.Errors) == 0 {
+			// un programa válido debe poder imprimirse
+			_ = program.String()
+		}
+	})
+}
@@ -12,7 +12,9 @@ type Parser struct {
 	Lexer    *lexer.Lexer
 	curToken token.Token
 	pekToken token.Token
-	Errors   []string // lista de errores encontrados
+	// tokens que van antes del siguiente del lexer, ver splitDecrement
+	pending []token.Token
+	Errors  []string // lista de errores encontrados
 }
 
 func New(lexer *lexer.Lexer) *Parser {
@@ -26,9 +28,23 @@ func New(lexer *lexer.Lexer) *Parser {
 // avanza el siguiente token
 func (p *Parser) nextToken() {
 	p.curToken = p.pekToken
+	if len(p.pending) > 0 {
+		p.pekToken = p.pending[0]
+		p.pending = p.pending[1:]
+		return
+	}
 	p.pekToken = p.Lexer.NextToken()
 }
 
+// Cambia el `--` actual por dos `-`. El lexer no sabe si `--` es un
+// decremento, así que `a--b`, `5--3` y `--x` se separan aquí.
+func (p *Parser) splitDecrement() {
+	tok := p.curToken
+	p.pending = append([]token.Token{p.pekToken}, p.pending...)
+	p.curToken = token.Token{Type: token.MINUS, Literal: "-", Line: tok.Line, Column: tok.Column}
+	p.pekToken = token.Token{Type: token.MINUS, Literal: "-", Line: tok.Line, Column: tok.Column + 1}
+}
+
 // compara el token actual y avanza
 func (p *Parser) advance(tType token.Type) {
 	if tType == p.curToken.Type {
@@ -71,7 +87,8 @@ func (p *Parser) block() *ast.BlockStmtNode {
 
 	p.advance(token.LBRACE)
 
-	for p.curToken.Type != token.RBRACE {
+	// paramos en EOF para no quedarnos en un ciclo con un bloque sin cerrar
+	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
 		resultExpr := p.statement()
 		p.skipSemicolon()
 		if resultExpr != nil {
@@ -83,25 +100,38 @@ func (p *Parser) block() *ast.BlockStmtNode {
 	return blockStmt
 }
 
-// statement ::= ( letStmt | returnStmt | expressionStmt ) ';'
+// statement ::= ( letStmt | returnStmt | whileStmt | forStmt | 'break' | 'continue' | expressionStmt ) ';'
 func (p *Parser) statement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.letStmt()
 	case token.RETURN:
 		return p.returnStmt()
 	case token.WHILE:
 		return p.whileStmt()
+	case token.FOR:
+		return p.forStmt()
+	case token.BREAK:
+		p.advance(token.BREAK)
+		return &ast.BreakStmtNode{}
+	case token.CONTINUE:
+		p.advance(token.CONTINUE)
+		return &ast.ContinueStmtNode{}
 	default:
 		return p.expressionStmt()
 	}
 }
 
-// letStmt ::= 'let' identifier '=' expression
+// letStmt ::= ( 'let' | 'const' ) identifier '=' expression
 func (p *Parser) letStmt() ast.Statement {
 	var letStmt = &ast.LetStmtNode{}
 
-	p.advance(token.LET)
+	if p.curToken.Type == token.CONST {
+		letStmt.Constant = true
+		p.advance(token.CONST)
+	} else {
+		p.advance(token.LET)
+	}
 	letStmt.Name = p.identifier()
 
 	p.advance(token.ASSIGN)
@@ -136,6 +166,67 @@ func (p *Parser) whileStmt() ast.Statement {
 	return whileStmt
 }
 
+// forStmt ::= 'for' '(' ( forInClause | forClauses ) ')' block
+func (p *Parser) forStmt() ast.Statement {
+	p.advance(token.FOR)
+	p.advance(token.LPAREN)
+
+	// `x in` o `k, v in` indican un for-in
+	if p.curToken.Type == token.IDENT && (p.pekToken.Type == token.IN || p.pekToken.Type == token.COMMA) {
+		return p.forInClause()
+	}
+	return p.forClauses()
+}
+
+// forClauses ::= ( letStmt | expressionStmt )? ';' expression? ';' expression?
+func (p *Parser) forClauses() ast.Statement {
+	var forStmt = &ast.ForStmtNode{}
+
+	switch p.curToken.Type {
+	case token.SEMICOLON:
+	case token.LET:
+		forStmt.Init = p.letStmt()
+	default:
+		forStmt.Init = p.expressionStmt()
+	}
+	p.advance(token.SEMICOLON)
+
+	if p.curToken.Type != token.SEMICOLON {
+		forStmt.Condition = p.expression()
+	}
+	p.advance(token.SEMICOLON)
+
+	if p.curToken.Type != token.RPAREN {
+		forStmt.Step = p.expression()
+	}
+	p.advance(token.RPAREN)
+
+	forStmt.Body = p.block()
+
+	return forStmt
+}
+
+// forInClause ::= identifier ( ',' identifier )? 'in' expression
+func (p *Parser) forInClause() ast.Statement {
+	var forStmt = &ast.ForInStmtNode{}
+
+	forStmt.Value = p.identifier()
+	if p.curToken.Type == token.COMMA {
+		// con dos variables la primera recibe la clave
+		p.advance(token.COMMA)
+		key := forStmt.Value
+		forStmt.Key = &key
+		forStmt.Value = p.identifier()
+	}
+	p.advance(token.IN)
+	forStmt.Iterable = p.expression()
+	p.advance(token.RPAREN)
+
+	forStmt.Body = p.block()
+
+	return forStmt
+}
+
 // expressionStmt ::= expression
 func (p *Parser) expressionStmt() ast.Statement {
 	var expressionStmt = &ast.ExpressionStmtNode{}
@@ -145,9 +236,84 @@ func (p *Parser) expressionStmt() ast.Statement {
 	return expressionStmt
 }
 
-// expression ::= logicOr
+// expression ::= assignment
 func (p *Parser) expression() ast.Expression {
-	return p.logicOr()
+	return p.assignment()
+}
+
+// operadores de asignación compuesta y el operador binario que aplican
+var compoundOperators = map[token.Type]token.Type{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+	token.INCREMENT:       token.PLUS,
+	token.DECREMENT:       token.MINUS,
+}
+
+// assignment ::= conditional ( ( '=' | '+=' | '-=' | '*=' | '/=' ) assignment )?
+func (p *Parser) assignment() ast.Expression {
+	node := p.conditional()
+	tok := p.curToken
+	if _, ok := compoundOperators[tok.Type]; tok.Type != token.ASSIGN && !ok {
+		return node
+	}
+	p.advance(tok.Type)
+	// la asignación es asociativa por la derecha: a[0] = b[0] += 1
+	value := p.assignment()
+
+	if !p.assignmentTarget(node) {
+		return nil
+	}
+	if tok.Type == token.ASSIGN {
+		return &ast.AssignExprNode{Target: node, Value: value}
+	}
+	return &ast.CompoundAssignExprNode{Target: node, Op: binaryOperator(tok), Value: value}
+}
+
+// valida el destino de una asignación
+func (p *Parser) assignmentTarget(node ast.Expression) bool {
+	switch node.(type) {
+	case *ast.IdentifierNode, *ast.IndexExprNode:
+		return true
+	case nil:
+		// el error ya fue reportado al parsear el destino
+		return false
+	}
+	msg := fmt.Sprintf("invalid assignment target: %s\n", node)
+	p.Errors = append(p.Errors, msg)
+	return false
+}
+
+// el token del operador binario de `+=` o `++`, en la misma posición
+func binaryOperator(tok token.Token) token.Token {
+	return token.Token{Type: compoundOperators[tok.Type], Literal: tok.Literal[:1], Line: tok.Line, Column: tok.Column}
+}
+
+// conditional ::= nullish ( '?' expression ':' conditional )?
+func (p *Parser) conditional() ast.Expression {
+	node := p.nullish()
+	if p.curToken.Type != token.QUESTION {
+		return node
+	}
+	p.advance(token.QUESTION)
+	consequence := p.expression()
+	p.advance(token.COLON)
+	// asociativa por la derecha: a ? b : c ? d : e
+	alternative := p.conditional()
+
+	return &ast.ConditionalExprNode{Condition: node, Consequence: consequence, Alternative: alternative}
+}
+
+// nullish ::= logicOr ( '??' nullish )?
+func (p *Parser) nullish() ast.Expression {
+	node := p.logicOr()
+	if p.curToken.Type != token.NULLISH {
+		return node
+	}
+	p.advance(token.NULLISH)
+
+	return &ast.CoalesceExprNode{Left: node, Right: p.nullish()}
 }
 
 // logicOr ::= logicAnd ('||' logicAnd)*
@@ -185,12 +351,91 @@ func (p *Parser) equality() ast.Expression {
 	return node
 }
 
-// comparison ::= term ( ( '<' | '<=' | '>' | '>=' ) term)
+// comparison ::= pipeline ( ( '<' | '<=' | '>' | '>=' ) pipeline)
 func (p *Parser) comparison() ast.Expression {
-	node := p.term()
+	node := p.pipeline()
 
 	for p.curToken.Type == token.LT || p.curToken.Type == token.LT_EQ ||
 		p.curToken.Type == token.GT || p.curToken.Type == token.GT_EQ {
+		tok := p.curToken
+		p.advance(tok.Type)
+		node = &ast.Binary{Left: node, Op: tok, Right: p.pipeline()}
+	}
+
+	return node
+}
+
+// `x |> f(a)` es `f(x, a)`: el valor de la izquierda pasa como primer
+// argumento. Va por debajo de la aritmética y por encima de las
+// comparaciones, así que `xs |> len() > 0` es `len(xs) > 0`.
+// pipeline ::= bitOr ( '|>' bitOr )*
+func (p *Parser) pipeline() ast.Expression {
+	node := p.bitOr()
+
+	for p.curToken.Type == token.PIPE {
+		p.advance(token.PIPE)
+		target := p.bitOr()
+		call, ok := target.(*ast.CallExprNode)
+		if !ok {
+			if target != nil {
+				msg := fmt.Sprintf("pipeline target must be a call: %s\n", target)
+				p.Errors = append(p.Errors, msg)
+			}
+			return nil
+		}
+		arguments := append([]ast.Expression{node}, call.Arguments...)
+		node = &ast.CallExprNode{Callee: call.Callee, Arguments: arguments}
+	}
+
+	return node
+}
+
+// Los operadores de bits van por encima de las comparaciones, así que
+// `flags & MASK == 0` es `(flags & MASK) == 0`.
+// bitOr ::= bitXor ( '|' bitXor )*
+func (p *Parser) bitOr() ast.Expression {
+	node := p.bitXor()
+
+	for p.curToken.Type == token.BIT_OR {
+		tok := p.curToken
+		p.advance(tok.Type)
+		node = &ast.Binary{Left: node, Op: tok, Right: p.bitXor()}
+	}
+
+	return node
+}
+
+// bitXor ::= bitAnd ( '^' bitAnd )*
+func (p *Parser) bitXor() ast.Expression {
+	node := p.bitAnd()
+
+	for p.curToken.Type == token.BIT_XOR {
+		tok := p.curToken
+		p.advance(tok.Type)
+		node = &ast.Binary{Left: node, Op: tok, Right: p.bitAnd()}
+	}
+
+	return node
+}
+
+// bitAnd ::= shift ( '&' shift )*
+func (p *Parser) bitAnd() ast.Expression {
+	node := p.shift()
+
+	for p.curToken.Type == token.BIT_AND {
+		tok := p.curToken
+		p.advance(tok.Type)
+		node = &ast.Binary{Left: node, Op: tok, Right: p.shift()}
+	}
+
+	return node
+}
+
+// shift ::= term ( ( '<<' | '>>' ) term )*
+func (p *Parser) shift() ast.Expression {
+	node := p.term()
+
+	for p.curToken.Type == token.SHIFT_LEFT || p.curToken.Type == token.SHIFT_RIGHT {
 		tok := p.curToken
 		p.advance(tok.Type)
 		node = &ast.Binary{Left: node, Op: tok, Right: p.term()}
@@ -212,11 +457,12 @@ func (p *Parser) term() ast.Expression {
 	return node
 }
 
-// factor ::= unary ( ( '*' | '/' ) unary )*
+// factor ::= unary ( ( '*' | '/' | '%' | '~/' ) unary )*
 func (p *Parser) factor() ast.Expression {
 	node := p.unary()
 
-	for p.curToken.Type == token.ASTERISK || p.curToken.Type == token.SLASH {
+	for p.curToken.Type == token.ASTERISK || p.curToken.Type == token.SLASH ||
+		p.curToken.Type == token.PERCENT || p.curToken.Type == token.FLOORDIV {
 		tok := p.curToken
 		p.advance(tok.Type)
 		node = &ast.Binary{Left: node, Op: tok, Right: p.unary()}
@@ -225,20 +471,39 @@ func (p *Parser) factor() ast.Expression {
 	return node
 }
 
-// unary ::= ( '!' | '-' ) unary | dot
+// unary ::= ( '!' | '-' | '~' ) unary | power
 func (p *Parser) unary() ast.Expression {
-	for p.curToken.Type == token.MINUS || p.curToken.Type == token.BANG {
+	// --x es -(-x)
+	if p.curToken.Type == token.DECREMENT {
+		p.splitDecrement()
+	}
+	for p.curToken.Type == token.MINUS || p.curToken.Type == token.BANG || p.curToken.Type == token.BIT_NOT {
 		tok := p.curToken
 		p.advance(tok.Type)
 		return &ast.Unary{Op: tok, Right: p.unary()}
 	}
 
-	return p.dot()
+	return p.power()
+}
+
+// power ::= dot ( '**' unary )?
+func (p *Parser) power() ast.Expression {
+	node := p.dot()
+
+	// asociativa por la derecha y más fuerte que el signo de la izquierda:
+	// -2 ** 2 es -(2 ** 2) y 2 ** 3 ** 2 es 2 ** (3 ** 2)
+	if p.curToken.Type == token.POWER {
+		tok := p.curToken
+		p.advance(token.POWER)
+		node = &ast.Binary{Left: node, Op: tok, Right: p.unary()}
+	}
+
+	return node
 }
 
-// dot ::= call ( '.' call )*
+// dot ::= postfix ( '.' postfix )*
 func (p *Parser) dot() ast.Expression {
-	node := p.call()
+	node := p.postfix()
 
 	for p.curToken.Type == token.DOT {
 		tok := p.curToken
@@ -249,20 +514,71 @@ func (p *Parser) dot() ast.Expression {
 	return node
 }
 
-// call ::= primary ( '(' arguments ? ')' )
+// postfix ::= call ( '++' | '--' )?
+func (p *Parser) postfix() ast.Expression {
+	node := p.call()
+	if p.curToken.Type == token.DECREMENT && !p.postfixDecrement(node) {
+		// a--b es a - (-b)
+		p.splitDecrement()
+		return node
+	}
+	if p.curToken.Type != token.INCREMENT && p.curToken.Type != token.DECREMENT {
+		return node
+	}
+	tok := p.curToken
+	p.advance(tok.Type)
+
+	if !p.assignmentTarget(node) {
+		return nil
+	}
+	one := &ast.IntegerNode{Value: 1}
+	return &ast.CompoundAssignExprNode{Target: node, Op: binaryOperator(tok), Value: one, Postfix: true}
+}
+
+// Indica si el `--` que sigue a `node` es un decremento: solo si `node`
+// se puede asignar y no le sigue en la misma línea un operando.
+func (p *Parser) postfixDecrement(node ast.Expression) bool {
+	switch node.(type) {
+	case *ast.IdentifierNode, *ast.IndexExprNode:
+	default:
+		return false
+	}
+	if p.pekToken.Line != p.curToken.Line {
+		return true
+	}
+	switch p.pekToken.Type {
+	case token.INT, token.STRING, token.IDENT, token.TRUE, token.FALSE, token.NULL,
+		token.FUNCTION, token.IF, token.LPAREN, token.LBRACKET, token.LBRACE, token.BANG, token.BIT_NOT:
+		return false
+	}
+	return true
+}
+
+// call ::= primary ( '(' arguments ? ')' | '[' index ']' | '?[' index ']' | '?.' IDENT )*
 func (p *Parser) call() ast.Expression {
 	node := p.primary()
+	optional := false
 	for {
-		if p.curToken.Type == token.LPAREN || p.curToken.Type == token.LBRACKET {
+		switch p.curToken.Type {
+		case token.LPAREN, token.LBRACKET, token.OPTIONAL_LBRACKET:
+			optional = optional || p.curToken.Type == token.OPTIONAL_LBRACKET
 			node = p.callExpression(node)
-		} else {
-			break
+		case token.OPTIONAL_DOT:
+			// a?.b es a?["b"]
+			optional = true
+			p.advance(token.OPTIONAL_DOT)
+			name := p.identifier()
+			node = &ast.IndexExprNode{Callee: node, Index: &ast.StringNode{Value: name.Value}, Optional: true}
+		default:
+			if optional {
+				return &ast.OptionalChainNode{Chain: node}
+			}
+			return node
 		}
 	}
-	return node
 }
 
-// primary ::= INTEGER | STRING | IDENT | TRUE | FALSE | NULL | FUNCTION | ARRAY | HASH | IF
+// primary ::= INTEGER | STRING | IDENT | TRUE | FALSE | NULL | FUNCTION | ARROW_FUNCTION | ARRAY | HASH | IF | grouping
 func (p *Parser) primary() ast.Expression {
 	tok := p.curToken
 	switch tok.Type {
@@ -275,7 +591,12 @@ func (p *Parser) primary() ast.Expression {
 		return &ast.StringNode{Value: tok.Literal}
 	case token.IDENT:
 		p.advance(token.IDENT)
-		return &ast.IdentifierNode{Value: tok.Literal}
+		identifier := &ast.IdentifierNode{Value: tok.Literal, Token: tok}
+		// x => x + 1
+		if p.curToken.Type == token.ARROW {
+			return p.arrowFunction([]ast.Expression{identifier})
+		}
+		return identifier
 	case token.TRUE:
 		p.advance(token.TRUE)
 		return &ast.BooleanNode{Value: true}
@@ -294,13 +615,19 @@ func (p *Parser) primary() ast.Expression {
 	case token.IF:
 		return p.ifExpression()
 	case token.LPAREN:
-		p.advance(token.LPAREN)
-		expr := p.expression()
-		p.advance(token.RPAREN)
-		return expr
+		return p.grouping()
+	case token.ILLEGAL:
+		p.nextToken()
+		msg := fmt.Sprintf("unknown character: %s\n", tok.Literal)
+		p.Errors = append(p.Errors, msg)
+		return nil
 	default:
 		msg := fmt.Sprintf("unknown token literal: %s\n", tok.Literal)
 		p.Errors = append(p.Errors, msg)
+		// consumimos el token para que cada sentencia avance al menos uno
+		if tok.Type != token.EOF {
+			p.nextToken()
+		}
 		return nil
 	}
 }
@@ -319,22 +646,44 @@ func (p *Parser) callExpression(callee ast.Expression) ast.Expression {
 		p.advance(token.RPAREN)
 		return callExpr
 
-	} else if p.curToken.Type == token.LBRACKET {
-		var indexExpr = &ast.IndexExprNode{
-			Callee: callee,
-		}
+	} else if p.curToken.Type == token.LBRACKET || p.curToken.Type == token.OPTIONAL_LBRACKET {
+		var index ast.Expression
+		optional := p.curToken.Type == token.OPTIONAL_LBRACKET
 
-		p.advance(token.LBRACKET)
-		if p.curToken.Type != token.RBRACKET {
-			indexExpr.Index = p.expression()
+		p.advance(p.curToken.Type)
+		if p.curToken.Type == token.RBRACKET {
+			p.Errors = append(p.Errors, "missing index expression\n")
+		} else if p.curToken.Type != token.COLON {
+			index = p.expression()
+		}
+		// callee[start:end] con los dos extremos opcionales
+		if p.curToken.Type == token.COLON {
+			return p.sliceExpression(callee, index, optional)
 		}
 		p.advance(token.RBRACKET)
 
-		return indexExpr
+		return &ast.IndexExprNode{Callee: callee, Index: index, Optional: optional}
 	}
 	return nil
 }
 
+// sliceExpression ::= callee '[' expression? ':' expression? ']'
+func (p *Parser) sliceExpression(callee ast.Expression, start ast.Expression, optional bool) ast.Expression {
+	var sliceExpr = &ast.SliceExprNode{
+		Callee:   callee,
+		Start:    start,
+		Optional: optional,
+	}
+
+	p.advance(token.COLON)
+	if p.curToken.Type != token.RBRACKET {
+		sliceExpr.End = p.expression()
+	}
+	p.advance(token.RBRACKET)
+
+	return sliceExpr
+}
+
 // functionLiteral ::= 'fn' '(' parameters ? ')'
 func (p *Parser) functionLiteral() ast.Expression {
 	var functionNode = &ast.FunLiteralNode{}
@@ -352,6 +701,63 @@ func (p *Parser) functionLiteral() ast.Expression {
 	return functionNode
 }
 
+// Con un solo token de adelanto no sabemos si `(a, b)` abre una lambda
+// hasta ver el `=>`, así que leemos expresiones y luego comprobamos que
+// sean identificadores.
+// grouping ::= '(' expression ')' | '(' arguments? ')' '=>' expression
+func (p *Parser) grouping() ast.Expression {
+	var expressions []ast.Expression
+
+	p.advance(token.LPAREN)
+	if p.curToken.Type != token.RPAREN {
+		expressions = p.arguments()
+	}
+	p.advance(token.RPAREN)
+
+	if len(expressions) == 1 && p.curToken.Type != token.ARROW {
+		return expressions[0]
+	}
+	// `()` y `(a, b)` solo pueden ser parámetros de una lambda
+	return p.arrowFunction(expressions)
+}
+
+// El cuerpo es una sola expresión cuyo valor se retorna, como si fuera
+// `fn(a, b) { expression }`.
+// arrowFunction ::= parameters '=>' expression
+func (p *Parser) arrowFunction(parameters []ast.Expression) ast.Expression {
+	var functionNode = &ast.FunLiteralNode{}
+	valid := true
+
+	for _, parameter := range parameters {
+		identifier, ok := parameter.(*ast.IdentifierNode)
+		if !ok {
+			if parameter != nil {
+				msg := fmt.Sprintf("invalid parameter: %s\n", parameter)
+				p.Errors = append(p.Errors, msg)
+			}
+			valid = false
+			continue
+		}
+		functionNode.Parameters = append(functionNode.Parameters, *identifier)
+	}
+
+	if p.curToken.Type != token.ARROW {
+		// reporta el `=>` que falta sin intentar leer un cuerpo
+		p.advance(token.ARROW)
+		return nil
+	}
+	p.advance(token.ARROW)
+	body := p.expression()
+	if !valid || body == nil {
+		return nil
+	}
+	functionNode.Body = &ast.BlockStmtNode{
+		Statements: []ast.Statement{&ast.ExpressionStmtNode{Expression: body}},
+	}
+
+	return functionNode
+}
+
 // arrayLiteral ::= '[' arguments? ']'
 func (p *Parser) arrayLiteral() ast.Expression {
 	var arrayNode = &ast.ArrayLiteralNode{}
@@ -456,5 +862,5 @@ func (p *Parser) arguments() []ast.Expression {
 func (p *Parser) identifier() ast.IdentifierNode {
 	tok := p.curToken
 	p.advance(token.IDENT)
-	return ast.IdentifierNode{Value: tok.Literal}
+	return ast.IdentifierNode{Value: tok.Literal, Token: tok}
 }
@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"MonkeyHabilis/ast"
+	"MonkeyHabilis/lexer"
+	"testing"
+)
+
+func parseProgram(t *testing.T, input string) *ast.ProgramNode {
+	t.Helper()
+	p := New(lexer.New(input))
+	program := p.Program()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors)
+	}
+	return program
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// statement / letStmt / returnStmt / whileStmt
+		{"let x = 5;", "let x = 5;\n"},
+		{"const x = 5;", "const x = 5;\n"},
+		{"return x;", "return x;\n"},
+		{"while (x < 10) { x; }", "while((x < 10)){\n\tx;\n};\n"},
+		// forStmt
+		{"for (x in [1, 2]) { x }", "for(x in [1,2]){\n\tx;\n};\n"},
+		{"for (k, v in h) { puts(k) }", "for(k, v in h){\n\tputs(k);\n};\n"},
+		{"while (true) { break; continue }", "while(true){\n\tbreak;\n\tcontinue;\n};\n"},
+		{"for (let i = 0; i < 3; i = i + 1) { i }", "for(let i = 0; (i < 3); (i = (i + 1))){\n\ti;\n};\n"},
+		{"for (;;) { break }", "for(; ; ){\n\tbreak;\n};\n"},
+		{"for (i; ; ) { }", "for(i; ; ){\n};\n"},
+		{"1; 2", "1;\n2;\n"},
+		// primary
+		{"5", "5;\n"},
+		{`"hola"`, "\"hola\";\n"},
+		{"foo", "foo;\n"},
+		{"true; false; null", "true;\nfalse;\nnull;\n"},
+		{"(5)", "5;\n"},
+		// unary
+		{"-a", "(- a);\n"},
+		{"!!true", "(! (! true));\n"},
+		// factor / term
+		{"a * b / c", "((a * b) / c);\n"},
+		{"a + b - c", "((a + b) - c);\n"},
+		{"a + b * c", "(a + (b * c));\n"},
+		{"(a + b) * c", "((a + b) * c);\n"},
+		{"-a * b", "((- a) * b);\n"},
+		{"~a & b", "((~ a) & b);\n"},
+		{"a % b ~/ c * d", "(((a % b) ~/ c) * d);\n"},
+		// power
+		{"a ** b ** c", "(a ** (b ** c));\n"},
+		{"-a ** b", "(- (a ** b));\n"},
+		{"a ** -b", "(a ** (- b));\n"},
+		{"a * b ** c", "(a * (b ** c));\n"},
+		{"a.b ** 2", "((a . b) ** 2);\n"},
+		// comparison / equality
+		{"a < b <= c", "((a < b) <= c);\n"},
+		{"a > b >= c", "((a > b) >= c);\n"},
+		{"a + 1 < b * 2", "((a + 1) < (b * 2));\n"},
+		{"a == b != c", "((a == b) != c);\n"},
+		{"a < b == c > d", "((a < b) == (c > d));\n"},
+		// bitOr / bitXor / bitAnd / shift
+		{"a | b ^ c & d", "(a | (b ^ (c & d)));\n"},
+		{"a & b << c + d", "(a & (b << (c + d)));\n"},
+		{"a << b >> c", "((a << b) >> c);\n"},
+		{"flags & mask == 0", "((flags & mask) == 0);\n"},
+		{"a | b < c && d", "(((a | b) < c) && d);\n"},
+		// conditional / nullish
+		{"a ? b : c", "(a ? b : c);\n"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e));\n"},
+		{"a || b ? c + 1 : d", "((a || b) ? (c + 1) : d);\n"},
+		{"x = a ? b : c", "(x = (a ? b : c));\n"},
+		{"a ? x = 1 : 2", "(a ? (x = 1) : 2);\n"},
+		{"a ?? b ?? c", "(a ?? (b ?? c));\n"},
+		{"a || b ?? c", "((a || b) ?? c);\n"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d);\n"},
+		// optional chaining
+		{"a?.b", "a?[\"b\"];\n"},
+		{"a?[0][1]", "a?[0][1];\n"},
+		{"a?.f(1)", "a?[\"f\"](1);\n"},
+		{"a?[1:]", "a?[1:];\n"},
+		{"a ? [1] : [2]", "(a ? [1] : [2]);\n"},
+		// pipeline
+		{"x |> f()", "f(x);\n"},
+		{"x |> f(1, 2)", "f(x,1,2);\n"},
+		{"x |> f() |> g(1)", "g(f(x),1);\n"},
+		{"a + 1 |> f()", "f((a + 1));\n"},
+		{"x |> f() > 0 && y |> g()", "((f(x) > 0) && g(y));\n"},
+		{"x |> f() == 1", "(f(x) == 1);\n"},
+		{"a | b |> f()", "f((a | b));\n"},
+		{"x |> h[\"f\"]()", "h[\"f\"](x);\n"},
+		{"x |> fn(a) { a }()", "fn(a){\n\ta;\n}(x);\n"},
+		// lambdas
+		{"x => x + 1", "fn(x){\n\t(x + 1);\n};\n"},
+		{"(a, b) => a * b", "fn(a,b){\n\t(a * b);\n};\n"},
+		{"() => 1", "fn(){\n\t1;\n};\n"},
+		{"(x) => x", "fn(x){\n\tx;\n};\n"},
+		{"(x)", "x;\n"},
+		{"x => y => x", "fn(x){\n\tfn(y){\n\tx;\n};\n};\n"},
+		{"f(x => x, 1)", "f(fn(x){\n\tx;\n},1);\n"},
+		{"x => c ? x : 0", "fn(x){\n\t(c ? x : 0);\n};\n"},
+		{"xs |> f(x => x)", "f(xs,fn(x){\n\tx;\n});\n"},
+		// logicAnd / logicOr
+		{"a && b || c && d", "((a && b) || (c && d));\n"},
+		{"a == b && c != d", "((a == b) && (c != d));\n"},
+		// dot
+		{"a.b", "(a . b);\n"},
+		// call / index
+		{"add(1, 2 * 3)", "add(1,(2 * 3));\n"},
+		{"f()", "f();\n"},
+		{"f(1)(2)", "f(1)(2);\n"},
+		{"a[1 + 1]", "a[(1 + 1)];\n"},
+		{"a[0][1]", "a[0][1];\n"},
+		{"f(x)[0]", "f(x)[0];\n"},
+		{"-a[0]", "(- a[0]);\n"},
+		// sliceExpression
+		{"a[1:2]", "a[1:2];\n"},
+		{"a[:n - 1]", "a[:(n - 1)];\n"},
+		{"a[-2:]", "a[(- 2):];\n"},
+		{"a[:]", "a[:];\n"},
+		{"a[1:][0]", "a[1:][0];\n"},
+		// assignment
+		{"a[0] = 1", "(a[0] = 1);\n"},
+		{`h["k"] = x + 1`, "(h[\"k\"] = (x + 1));\n"},
+		{"a[0] = b[1] = 2", "(a[0] = (b[1] = 2));\n"},
+		{"let x = a[i] = 5;", "let x = (a[i] = 5);\n"},
+		{"x = y = 1", "(x = (y = 1));\n"},
+		{"x += y *= 2", "(x += (y *= 2));\n"},
+		{"a[i] -= 1 + 2", "(a[i] -= (1 + 2));\n"},
+		{"x /= 2", "(x /= 2);\n"},
+		// postfix
+		{"x++", "(x++);\n"},
+		{"a[0]--", "(a[0]--);\n"},
+		{"-x++ * 2", "((- (x++)) * 2);\n"},
+		{"x++ + 1", "((x++) + 1);\n"},
+		{"for (let i = 0; i < 3; i++) { }", "for(let i = 0; (i < 3); (i++)){\n};\n"},
+		// un `--` que no puede ser postfijo es una resta de un negativo
+		{"a--b", "(a - (- b));\n"},
+		{"5--3", "(5 - (- 3));\n"},
+		{"a[0]--1", "(a[0] - (- 1));\n"},
+		{"f()--x * 2", "(f() - ((- x) * 2));\n"},
+		{"--x", "(- (- x));\n"},
+		{"- -x", "(- (- x));\n"},
+		{"a - --b", "(a - (- (- b)));\n"},
+		{"x-- - 1", "((x--) - 1);\n"},
+		{"x--\ny", "(x--);\ny;\n"},
+		// functionLiteral
+		{"fn(x, y) { x + y; }", "fn(x,y){\n\t(x + y);\n};\n"},
+		{"fn() { }", "fn(){\n};\n"},
+		{"fn(x) { x }(5)", "fn(x){\n\tx;\n}(5);\n"},
+		// arrayLiteral / hashLiteral
+		{"[1, 2 * 2, 3]", "[1,(2 * 2),3];\n"},
+		{"[]", "[];\n"},
+		{`{"a": 1}`, "{\"a\": 1};\n"},
+		{"{}", "{};\n"},
+		// ifExpression
+		{"if (x < y) { x }", "if ((x < y)){\n\tx;\n};\n"},
+		{"if (x) { x } else { y }", "if (x){\n\tx;\n}else{\n\ty;\n};\n"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestLetStatement(t *testing.T) {
+	program := parseProgram(t, "let answer = 42;")
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.LetStmtNode)
+	if !ok {
+		t.Fatalf("expected *ast.LetStmtNode, got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "answer" {
+		t.Errorf("expected name answer, got=%s", stmt.Name.Value)
+	}
+	integer, ok := stmt.Value.(*ast.IntegerNode)
+	if !ok || integer.Value != 42 {
+		t.Errorf("expected integer 42, got=%s", stmt.Value)
+	}
+}
+
+func TestHashLiteralPairs(t *testing.T) {
+	program := parseProgram(t, `{"one": 1, "two": 2, "three": 3}`)
+	hash, ok := program.Statements[0].(*ast.ExpressionStmtNode).Expression.(*ast.HashLiteralNode)
+	if !ok {
+		t.Fatalf("expected *ast.HashLiteralNode, got=%T", program.Statements[0].(*ast.ExpressionStmtNode).Expression)
+	}
+	expected := map[string]int64{"one": 1, "two": 2, "three": 3}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("expected %d pairs, got=%d", len(expected), len(hash.Pairs))
+	}
+	for key, value := range hash.Pairs {
+		if expected[key.(*ast.StringNode).Value] != value.(*ast.IntegerNode).Value {
+			t.Errorf("wrong value for key %s: %s", key, value)
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []string{
+		"let = 5",
+		"let x 5",
+		"const = 5",
+		"fn(x { x }",
+		"(1 + 2",
+		"[1, 2",
+		"@",
+		"fn(1) { }",
+		"if (x) { 1",
+		"a[]",
+		"a[1:2",
+		"a[1:2:3]",
+		"1 = 1",
+		"f(x) = 1",
+		"a[1:2] = 3",
+		"1 += 2",
+		"f() -= 1",
+		"a[1:2] *= 3",
+		"f()++",
+		"1--",
+		"x++ ++",
+		"a ? b",
+		"a ? b : ",
+		"a ?? ",
+		"a?.1",
+		"a?[0] = 1",
+		"a?.b++",
+		"c ?[1] : [2]",
+		"x |> f",
+		"x |> f() + 1",
+		"x |> ",
+		"x |> h?.f()",
+		"(a, b)",
+		"()",
+		"(a, 1) => a",
+		"(a + b) => a",
+		"x => ",
+		"() => { let a = 1; a }",
+		"for (x [1]) { x }",
+		"for (1 in a) { }",
+		"for (a, b, c in h) { }",
+		"for (let i = 0; i < 3) { }",
+		"for (let i = 0) { }",
+		") ] }",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.Program()
+		if len(p.Errors) == 0 {
+			t.Errorf("%q: expected parser errors", input)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// nombre del archivo de historial dentro del home del usuario
+const HISTORY_FILE = ".monkeyhabilis_history"
+
+// máximo de entradas que guardamos en el historial
+const HISTORY_SIZE = 1000
+
+// teclas de control que maneja el editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// Editor de líneas para la terminal: movimiento del cursor,
+// historial persistente, búsqueda con Ctrl-R y autocompletado.
+type lineEditor struct {
+	in          *bufio.Reader
+	out         io.Writer
+	fd          uintptr
+	history     []string
+	historyPath string
+	fileLines   int // líneas escritas en el archivo de historial
+	complete    func(prefix string) []string
+
+	buf  []rune // la línea que se está editando
+	pos  int    // posición del cursor dentro de buf
+	tabs int    // tabs seguidos (el segundo lista los candidatos)
+}
+
+// Crea el editor y carga el historial del usuario. `reader` es el buffer
+// sobre `in` que la REPL comparte con los builtins de entrada.
+func newLineEditor(in *os.File, reader *bufio.Reader, out io.Writer, complete func(prefix string) []string) *lineEditor {
+	e := &lineEditor{
+		in:       reader,
+		out:      out,
+		fd:       in.Fd(),
+		complete: complete,
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		e.historyPath = filepath.Join(home, HISTORY_FILE)
+		e.loadHistory()
+	}
+	return e
+}
+
+// carga el historial desde el disco
+func (e *lineEditor) loadHistory() {
+	file, err := os.Open(e.historyPath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		e.fileLines += 1
+		if line := scanner.Text(); line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	e.history = trimHistory(e.history)
+}
+
+// agrega una línea al historial y la guarda en el disco
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = trimHistory(append(e.history, line))
+	if e.historyPath == "" {
+		return
+	}
+	// cuando el archivo supera el límite lo reescribimos con las últimas
+	// entradas; mientras tanto solo agregamos la línea al final.
+	if e.fileLines >= HISTORY_SIZE {
+		e.rewriteHistory()
+		return
+	}
+	file, err := os.OpenFile(e.historyPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+	e.fileLines += 1
+}
+
+// reemplaza el archivo de historial por las entradas en memoria
+func (e *lineEditor) rewriteHistory() {
+	tmp := e.historyPath + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	for _, line := range e.history {
+		fmt.Fprintln(file, line)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, e.historyPath); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	e.fileLines = len(e.history)
+}
+
+// conserva solo las últimas HISTORY_SIZE entradas
+func trimHistory(history []string) []string {
+	if len(history) > HISTORY_SIZE {
+		return history[len(history)-HISTORY_SIZE:]
+	}
+	return history
+}
+
+// Lee una línea en modo raw. Devuelve io.EOF con Ctrl-D sobre una línea vacía.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	e.buf = []rune{}
+	e.pos = 0
+	e.tabs = 0
+	// índice del historial que se está mostrando (len = línea nueva)
+	historyIndex := len(e.history)
+	pending := ""
+
+	e.refresh(prompt)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r != keyTab {
+			e.tabs = 0
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			io.WriteString(e.out, "\r\n")
+			line := string(e.buf)
+			e.addHistory(line)
+			return line, nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			e.buf = []rune{}
+			e.pos = 0
+			historyIndex = len(e.history)
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.pos -= 1
+				e.deleteAt(e.pos)
+			}
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlB:
+			if e.pos > 0 {
+				e.pos -= 1
+			}
+		case keyCtrlF:
+			if e.pos < len(e.buf) {
+				e.pos += 1
+			}
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = e.buf[e.pos:]
+			e.pos = 0
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start -= 1
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start -= 1
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			historyIndex, pending = e.showHistory(historyIndex, historyIndex-1, pending)
+		case keyCtrlN:
+			historyIndex, pending = e.showHistory(historyIndex, historyIndex+1, pending)
+		case keyCtrlR:
+			if e.reverseSearch(prompt) {
+				io.WriteString(e.out, "\r\n")
+				line := string(e.buf)
+				e.addHistory(line)
+				return line, nil
+			}
+		case keyTab:
+			e.tabs += 1
+			e.completeWord(prompt)
+		case keyEscape:
+			switch e.readEscape() {
+			case 'A':
+				historyIndex, pending = e.showHistory(historyIndex, historyIndex-1, pending)
+			case 'B':
+				historyIndex, pending = e.showHistory(historyIndex, historyIndex+1, pending)
+			case 'C':
+				if e.pos < len(e.buf) {
+					e.pos += 1
+				}
+			case 'D':
+				if e.pos > 0 {
+					e.pos -= 1
+				}
+			case 'H':
+				e.pos = 0
+			case 'F':
+				e.pos = len(e.buf)
+			case '~':
+				e.deleteAt(e.pos)
+			}
+		default:
+			if r >= ' ' {
+				e.insert(r)
+			}
+		}
+		e.refresh(prompt)
+	}
+}
+
+// interpreta una secuencia de escape (flechas, inicio, fin, suprimir)
+// y devuelve su letra final o 0 si no la reconocemos.
+func (e *lineEditor) readEscape() rune {
+	first, _, err := e.in.ReadRune()
+	if err != nil || (first != '[' && first != 'O') {
+		return 0
+	}
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return r
+	case r >= '0' && r <= '9':
+		// secuencias del tipo ESC [ n ~
+		last, _, err := e.in.ReadRune()
+		if err != nil || last != '~' {
+			return 0
+		}
+		switch r {
+		case '3':
+			return '~'
+		case '1', '7':
+			return 'H'
+		case '4', '8':
+			return 'F'
+		}
+	}
+	return 0
+}
+
+// inserta un caracter en la posición del cursor
+func (e *lineEditor) insert(r rune) {
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = r
+	e.pos += 1
+}
+
+// elimina el caracter en la posición indicada
+func (e *lineEditor) deleteAt(index int) {
+	if index < 0 || index >= len(e.buf) {
+		return
+	}
+	e.buf = append(e.buf[:index], e.buf[index+1:]...)
+}
+
+// reemplaza la línea actual por la entrada del historial indicada.
+// `pending` conserva lo que se estaba escribiendo antes de navegar.
+func (e *lineEditor) showHistory(current int, next int, pending string) (int, string) {
+	if next < 0 || next > len(e.history) {
+		return current, pending
+	}
+	if current == len(e.history) {
+		pending = string(e.buf)
+	}
+	if next == len(e.history) {
+		e.buf = []rune(pending)
+	} else {
+		e.buf = []rune(e.history[next])
+	}
+	e.pos = len(e.buf)
+	return next, pending
+}
+
+// búsqueda incremental hacia atrás en el historial (Ctrl-R).
+// Devuelve true si el usuario aceptó la línea con Enter.
+func (e *lineEditor) reverseSearch(prompt string) bool {
+	query := []rune{}
+	match := ""
+	start := len(e.history) - 1
+
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), match)
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false
+		}
+		switch r {
+		case keyEnter, '\n':
+			e.buf = []rune(match)
+			e.pos = len(e.buf)
+			return true
+		case keyCtrlC, keyCtrlG:
+			e.refresh(prompt)
+			return false
+		case keyCtrlR:
+			// buscamos la siguiente coincidencia más antigua
+			start -= 1
+		case keyBackspace, keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			start = len(e.history) - 1
+		default:
+			if r < ' ' {
+				// cualquier otra tecla de control acepta la coincidencia para editarla
+				e.buf = []rune(match)
+				e.pos = len(e.buf)
+				return false
+			}
+			query = append(query, r)
+		}
+
+		match, start = searchHistory(e.history, string(query), start)
+	}
+}
+
+// busca hacia atrás desde `start` la entrada más reciente que contenga
+// `query`. Devuelve la coincidencia y su índice, o "" y `start` si no hay.
+func searchHistory(history []string, query string, start int) (string, int) {
+	if start >= len(history) {
+		start = len(history) - 1
+	}
+	for i := start; i >= 0; i-- {
+		if strings.Contains(history[i], query) {
+			return history[i], i
+		}
+	}
+	return "", start
+}
+
+// autocompleta la palabra que está bajo el cursor
+func (e *lineEditor) completeWord(prompt string) {
+	if e.complete == nil {
+		return
+	}
+	start := e.pos
+	for start > 0 && isWordChar(e.buf[start-1]) {
+		start -= 1
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+
+	// completamos hasta el prefijo común de todos los candidatos
+	common := []rune(commonPrefix(candidates))
+	if length := len([]rune(prefix)); len(common) > length {
+		for _, r := range common[length:] {
+			e.insert(r)
+		}
+		return
+	}
+
+	// con el segundo tab mostramos los candidatos
+	if len(candidates) > 1 && e.tabs > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+// redibuja la línea y coloca el cursor
+func (e *lineEditor) refresh(prompt string) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(e.buf))
+	io.WriteString(e.out, "\r")
+	if column := len([]rune(prompt)) + e.pos; column > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", column)
+	}
+}
+
+// caracteres que forman parte de un identificador
+func isWordChar(r rune) bool {
+	return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+}
+
+// prefijo común de todos los candidatos. Compara runas para no cortar
+// un caracter de varios bytes por la mitad.
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	common := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		runes := []rune(candidate)
+		length := 0
+		for length < len(common) && length < len(runes) && common[length] == runes[length] {
+			length += 1
+		}
+		common = common[:length]
+	}
+	return string(common)
+}
+
+// devuelve los candidatos ordenados y sin repetir que empiezan con `prefix`
+func filterCandidates(prefix string, names []string) []string {
+	seen := map[string]bool{}
+	candidates := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
@@ -0,0 +1,109 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterCandidates(t *testing.T) {
+	names := []string{"puts", "push", "len", "push", "print", "p"}
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"pu", []string{"push", "puts"}},
+		{"p", []string{"p", "print", "push", "puts"}},
+		{"x", []string{}},
+	}
+
+	for _, tt := range tests {
+		if actual := filterCandidates(tt.prefix, names); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%q: want=%v, got=%v", tt.prefix, tt.expected, actual)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		candidates []string
+		expected   string
+	}{
+		{[]string{"push", "puts"}, "pu"},
+		{[]string{"len"}, "len"},
+		{[]string{"first", "last"}, ""},
+		{[]string{}, ""},
+		{[]string{"año", "añadir"}, "añ"},
+		{[]string{"cañón", "cañal"}, "cañ"},
+		// é y è comparten su primer byte: no cortamos el caracter por la mitad
+		{[]string{"aé", "aè"}, "a"},
+	}
+
+	for _, tt := range tests {
+		if actual := commonPrefix(tt.candidates); actual != tt.expected {
+			t.Errorf("%v: want=%q, got=%q", tt.candidates, tt.expected, actual)
+		}
+	}
+}
+
+func TestSearchHistory(t *testing.T) {
+	history := []string{"let a = 1", "puts(a)", "let b = 2", "len(b)"}
+
+	tests := []struct {
+		query         string
+		start         int
+		expectedMatch string
+		expectedIndex int
+	}{
+		{"let", 3, "let b = 2", 2},
+		// Ctrl-R de nuevo busca desde la entrada anterior a la coincidencia
+		{"let", 1, "let a = 1", 0},
+		{"a", 3, "puts(a)", 1},
+		{"", 3, "len(b)", 3},
+		{"while", 3, "", 3},
+		{"let", 10, "let b = 2", 2},
+		{"let", -1, "", -1},
+	}
+
+	for _, tt := range tests {
+		match, index := searchHistory(history, tt.query, tt.start)
+		if match != tt.expectedMatch || index != tt.expectedIndex {
+			t.Errorf("%q from %d: want=(%q, %d), got=(%q, %d)", tt.query, tt.start, tt.expectedMatch, tt.expectedIndex, match, index)
+		}
+	}
+}
+
+func TestHistoryFileIsTrimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+	e := &lineEditor{historyPath: path}
+
+	for i := 0; i < HISTORY_SIZE+10; i++ {
+		e.addHistory(fmt.Sprintf("line %d", i))
+	}
+	// las líneas vacías y repetidas no se guardan
+	e.addHistory("   ")
+	e.addHistory(fmt.Sprintf("line %d", HISTORY_SIZE+9))
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read history: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) > HISTORY_SIZE {
+		t.Fatalf("history file has %d lines, limit is %d", len(lines), HISTORY_SIZE)
+	}
+	if last := lines[len(lines)-1]; last != fmt.Sprintf("line %d", HISTORY_SIZE+9) {
+		t.Errorf("wrong last line: %q", last)
+	}
+
+	// al cargarlo de nuevo vemos las mismas entradas que en memoria
+	loaded := &lineEditor{historyPath: path}
+	loaded.loadHistory()
+	if !reflect.DeepEqual(loaded.history, e.history) {
+		t.Errorf("loaded history differs: %d entries, want %d", len(loaded.history), len(e.history))
+	}
+}
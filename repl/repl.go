@@ -5,33 +5,40 @@ import (
 	"MonkeyHabilis/lexer"
 	"MonkeyHabilis/object"
 	"MonkeyHabilis/parser"
+	"MonkeyHabilis/token"
 	"MonkeyHabilis/vm"
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-
 	objectPool := []object.Object{}
-	globals := make([]object.Object, vm.GLOBAL_SIZE)
+	globals := []object.Object{}
 
+	builtins := object.NewBuiltinRegistry()
 	symbolTable := compiler.NewSymbolTable()
-	for i, builtin := range object.Builtins {
-		symbolTable.DefineBuiltin(i, builtin.Name)
-	}
+	symbolTable.DefineBuiltins(builtins)
+
+	// los builtins `input` y `readline` leen del mismo buffer que la REPL
+	reader := bufio.NewReader(in)
+	config := vm.DefaultConfig()
+	config.Builtins = builtins
+	config.Stdout = out
+	config.Stdin = reader
+
+	readLine := newLineReader(in, reader, out, symbolTable)
 
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, ok := readLine()
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -43,6 +50,9 @@ func Start(in io.Reader, out io.Writer) {
 
 		comp := compiler.NewWithState(symbolTable, objectPool)
 		err := comp.Compile(program)
+		// aunque falle, la tabla puede haber guardado consts que apuntan a
+		// literales del pool
+		objectPool = comp.GetByteCode().ObjectPool
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
 			continue
@@ -50,15 +60,15 @@ func Start(in io.Reader, out io.Writer) {
 
 		/**************************INICIO DEBUG************************/
 		strBytecode := comp.PrintInstructions(comp.GetInstructions())
-		fmt.Print(strBytecode)
+		fmt.Fprint(out, strBytecode)
 		/**************************FIN DEBUG***************************/
 
-		// Obtenemos el bytecode y mantenemos la lista de constantes
 		byteCode := comp.GetByteCode()
-		objectPool = byteCode.ObjectPool
 
-		machine := vm.NewWithGlobalsStore(byteCode, globals)
+		machine := vm.NewWithConfig(byteCode, globals, config)
 		err = machine.Run()
+		// la vm pudo hacer crecer el array de globales
+		globals = machine.Globals()
 
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
@@ -71,6 +81,50 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+// Devuelve la función que lee cada línea de la REPL. Si la entrada es
+// una terminal usamos el editor de líneas, si no un simple scanner.
+func newLineReader(in io.Reader, reader *bufio.Reader, out io.Writer, symbolTable *compiler.SymbolTable) func() (string, bool) {
+	if file, ok := in.(*os.File); ok && isTerminal(file.Fd()) {
+		editor := newLineEditor(file, reader, out, func(prefix string) []string {
+			return completions(prefix, symbolTable)
+		})
+		return func() (string, bool) {
+			line, err := editor.readLine(PROMPT)
+			return line, err == nil
+		}
+	}
+	if file, ok := in.(*os.File); ok && !rawModeSupported && isCharDevice(file) {
+		io.WriteString(out, "Line editing is not supported on this platform: no history or tab completion.\n")
+	}
+
+	return func() (string, bool) {
+		io.WriteString(out, PROMPT)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", false
+		}
+		return strings.TrimRight(line, "\r\n"), true
+	}
+}
+
+// indica si el archivo es un dispositivo de caracteres, como una terminal
+func isCharDevice(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// candidatos para el autocompletado: palabras reservadas,
+// builtins y los globales definidos en la sesión.
+func completions(prefix string, symbolTable *compiler.SymbolTable) []string {
+	names := []string{}
+	for keyword := range token.Keywords {
+		names = append(names, keyword)
+	}
+	names = append(names, symbolTable.Names()...)
+
+	return filterCandidates(prefix, names)
+}
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
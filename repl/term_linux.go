@@ -0,0 +1,11 @@
+//go:build linux
+
+package repl
+
+import "syscall"
+
+// peticiones de ioctl para leer y aplicar la configuración de la terminal
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
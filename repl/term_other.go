@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package repl
+
+import "errors"
+
+// en otras plataformas no manejamos el modo raw, la REPL
+// cae al lector de líneas simple.
+const rawModeSupported = false
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw mode not supported on this platform")
+}
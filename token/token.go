@@ -7,6 +7,8 @@ type Type string
 type Token struct {
 	Type    Type
 	Literal string
+	Line    int // línea del primer caracter, empieza en 1
+	Column  int // columna (en bytes) del primer caracter, empieza en 1
 }
 
 // lista de constantes (en otros paquetes se acceden así: `token.EOF`)
@@ -25,6 +27,9 @@ const (
 	BANG     = "BANG"
 	ASTERISK = "ASTERISK"
 	SLASH    = "SLASH"
+	PERCENT  = "PERCENT"
+	POWER    = "POWER"     // **
+	FLOORDIV = "FLOOR_DIV" // ~/
 
 	LT     = "LT"
 	LT_EQ  = "LT_EQ"
@@ -40,6 +45,29 @@ const (
 	AND       = "AND"
 	OR        = "OR"
 
+	// asignación compuesta
+	PLUS_ASSIGN     = "PLUS_ASSIGN"
+	MINUS_ASSIGN    = "MINUS_ASSIGN"
+	ASTERISK_ASSIGN = "ASTERISK_ASSIGN"
+	SLASH_ASSIGN    = "SLASH_ASSIGN"
+	INCREMENT       = "INCREMENT"
+	DECREMENT       = "DECREMENT"
+
+	QUESTION          = "QUESTION"
+	NULLISH           = "NULLISH"           // ??
+	OPTIONAL_DOT      = "OPTIONAL_DOT"      // ?.
+	OPTIONAL_LBRACKET = "OPTIONAL_LBRACKET" // ?[
+
+	PIPE  = "PIPE"  // |>
+	ARROW = "ARROW" // =>
+
+	BIT_AND     = "BIT_AND"
+	BIT_OR      = "BIT_OR"
+	BIT_XOR     = "BIT_XOR"
+	BIT_NOT     = "BIT_NOT"
+	SHIFT_LEFT  = "SHIFT_LEFT"
+	SHIFT_RIGHT = "SHIFT_RIGHT"
+
 	LPAREN   = "LPAREN"
 	RPAREN   = "RPAREN"
 	LBRACE   = "LBRACE"
@@ -50,6 +78,7 @@ const (
 	// keywords
 	FUNCTION = "FUN"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	NULL     = "NULL"
@@ -57,19 +86,28 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 // Diccionario con las palabras reservadas
 var Keywords = map[string]Type{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"null":   NULL,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"while":  WHILE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"null":     NULL,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // ¿Este literal es una palabra reservada o un identificador?
@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"MonkeyHabilis/compiler"
+	"MonkeyHabilis/lexer"
+	"MonkeyHabilis/parser"
+	"io"
+	"strings"
+	"testing"
+)
+
+// programas representativos para el corpus inicial
+var fuzzSeeds = []string{
+	"",
+	"1 + 2 * 3 - 4 / 2",
+	"let x = 5; let y = x * 2; y",
+	`"mon" + "key"`,
+	"if (1) {}",
+	"if (true) {} else { 1 }",
+	"if (false) { 1 }",
+	"[]; [1]; [1, 2, 3][1]",
+	`{}; {"a": 1}; {"a": 1, "b": 2}["b"]`,
+	"[1, 2][5]",
+	`"abc"[1]`,
+	"1[0]",
+	"[0, A]",
+	`"0"[]`,
+	"[1, 2, 3][1:]; [1, 2, 3][-1:]; \"abc\"[:-1]; [][5:]",
+	`let a = [1]; a[0] = a; let h = {}; h["x"] = h; puts(a, h)`,
+	`for (k, v in {"a": [1, 2]}) { for (x in v) { puts(k, x) } }; for (c in "ab") { c }; for (i in range(3)) { i }`,
+	"let i = 0; while (i < 3) { i = i + 1; if (i == 1) { continue } for (x in [i]) { break } }",
+	"break; continue",
+	"let s = 0; for (let i = 0; i < 4; i = i + 1) { if (i == 1) { continue } s = s + i }; for (;;) { break }",
+	"true + 1",
+	"-true; !5",
+	"1 / 0",
+	"let f = fn(a, b) { a + b }; f(1)",
+	"let f = fn() { f() }; f()",
+	"let counter = fn(x) { fn(y) { x + y } }; counter(1)(2)",
+	`len("abc"); first([1]); rest([]); push([], 1); puts("x")`,
+	"len(1); first(1); last(); rest(1, 2)",
+	"return 1",
+	"let x = 1",
+	"fn() { let a = 1 }()",
+	"while (true) { 1 }",
+}
+
+// Compila y ejecuta entradas arbitrarias: ni el compilador ni la vm
+// deben entrar en pánico, los fallos tienen que volver como error.
+func FuzzRun(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.Program()
+		if len(p.Errors) != 0 {
+			return
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return
+		}
+
+		// límites pequeños para que los bucles infinitos y las
+		// recursiones no dominen el tiempo del fuzzer
+		config := DefaultConfig()
+		config.MaxFrames = 64
+		config.InstructionBudget = 100000
+		config.MemoryLimit = 1 << 20
+		config.Stdout = io.Discard
+		config.Stdin = strings.NewReader("")
+
+		machine := NewWithConfig(comp.GetByteCode(), nil, config)
+		// un *InternalError es un pánico recuperado: un bug de la vm
+		if err, ok := machine.Run().(*InternalError); ok {
+			t.Fatalf("%q: %s", input, err)
+		}
+	})
+}
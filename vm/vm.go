@@ -4,18 +4,161 @@ import (
 	"MonkeyHabilis/code"
 	"MonkeyHabilis/compiler"
 	"MonkeyHabilis/object"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
 )
 
-var STACK_SIZE = 2048
+// valores por defecto de la configuración
+const (
+	STACK_SIZE  = 2048  // slots de la pila
+	MAX_FRAMES  = 1024  // profundidad máxima de llamadas
+	GLOBAL_SIZE = 65536 // capacidad máxima de variables globales
+)
+
+// cada cuántas instrucciones revisamos si el contexto fue cancelado
+const CONTEXT_CHECK_INTERVAL = 1024
+
+// La vm ejecutó más instrucciones de las permitidas por Config.InstructionBudget
+var ErrBudgetExceeded = errors.New("instruction budget exceeded")
+
+// La ejecución se detuvo porque el contexto fue cancelado o expiró.
+// errors.Is(err, context.Canceled) y context.DeadlineExceeded funcionan sobre él.
+type CancelledError struct {
+	Cause error
+}
+
+func (e *CancelledError) Error() string { return "execution cancelled: " + e.Cause.Error() }
+func (e *CancelledError) Unwrap() error { return e.Cause }
+
+// El script intentó reservar más memoria de la permitida por Config.MemoryLimit
+type MemoryLimitError struct {
+	Limit     int64 // límite configurado
+	Allocated int64 // memoria ya contabilizada
+	Requested int64 // memoria que se intentó reservar
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("memory limit exceeded: requested %d bytes with %d of %d bytes already allocated", e.Requested, e.Allocated, e.Limit)
+}
+
+// El bytecode no es válido: índices fuera de rango, saltos fuera de la
+// función u opcodes desconocidos. Indica un bug del compilador o un
+// bytecode armado a mano, no un error del script. Se distingue con
+// errors.Is.
+var ErrInvalidBytecode = errors.New("invalid bytecode")
+
+// La pila quedó vacía cuando una instrucción esperaba un valor,
+// indica un bytecode mal formado.
+var ErrStackUnderflow = invalidBytecode("stack underflow")
+
+// error de bytecode mal formado; conserva el mensaje y envuelve a
+// ErrInvalidBytecode
+type bytecodeError struct {
+	message string
+}
+
+func (e *bytecodeError) Error() string { return e.message }
+func (e *bytecodeError) Unwrap() error { return ErrInvalidBytecode }
+
+func invalidBytecode(format string, a ...interface{}) error {
+	return &bytecodeError{message: fmt.Sprintf(format, a...)}
+}
+
+// Un pánico de Go dentro de la vm (o de un builtin) convertido en error.
+// Indica un bug del intérprete, no del script.
+type InternalError struct {
+	Cause  interface{} // el valor recuperado del pánico
+	Depth  int         // índice del frame que se estaba ejecutando
+	IP     int         // instrucción que se estaba ejecutando
+	OpCode string      // nemónico de esa instrucción
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("internal error: %v (frame %d, instruction %d %s)", e.Cause, e.Depth, e.IP, e.OpCode)
+}
+
+// Permite usar errors.Is/As cuando el pánico fue con un error
+func (e *InternalError) Unwrap() error {
+	err, _ := e.Cause.(error)
+	return err
+}
+
+// Categoría de un error de ejecución causado por el script
+type ErrorKind string
+
+// Lista de categorías
+const (
+	DivisionByZero  ErrorKind = "DIVISION_BY_ZERO"
+	IndexOutOfRange ErrorKind = "INDEX_OUT_OF_RANGE"
+	TypeMismatch    ErrorKind = "TYPE_MISMATCH"
+	WrongArity      ErrorKind = "WRONG_ARITY"
+	NotCallable     ErrorKind = "NOT_CALLABLE"
+	IntegerOverflow ErrorKind = "INTEGER_OVERFLOW"
+	InvalidOperand  ErrorKind = "INVALID_OPERAND"
+	StackOverflow   ErrorKind = "STACK_OVERFLOW" // la pila o las llamadas superan la configuración
+	LimitExceeded   ErrorKind = "LIMIT_EXCEEDED" // más globales de las permitidas
+)
 
-// estamos usando un int genérico por lo tanto este número es falso.
-var GLOBAL_SIZE = 65536
+// Un error del script: dividir entre cero, indexar fuera de rango, operar
+// con tipos incompatibles o valores inválidos, desbordar una potencia,
+// llamar mal a una función o superar los límites de la pila y de las
+// globales. Se distingue con errors.As y el campo Kind.
+type RuntimeError struct {
+	Kind    ErrorKind
+	Message string
+}
+
+func (e *RuntimeError) Error() string { return e.Message }
+
+func runtimeError(kind ErrorKind, format string, a ...interface{}) error {
+	return &RuntimeError{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
 
 var TRUE = &object.Boolean{Value: true}
 var FALSE = &object.Boolean{Value: false}
 var NULL = &object.Null{}
 
+// registro usado cuando no se indica uno propio
+var defaultBuiltins = object.NewBuiltinRegistry()
+
+// Config agrupa los límites de una máquina virtual. Cada vm tiene
+// los suyos, así podemos ejecutar muchas vms pequeñas a la vez.
+type Config struct {
+	StackSize  int                     // slots de la pila
+	MaxFrames  int                     // profundidad máxima de llamadas
+	MaxGlobals int                     // capacidad máxima de globales (el array crece bajo demanda)
+	Builtins   *object.BuiltinRegistry // builtins para OpGetBuiltin (nil = estándar)
+
+	InstructionBudget int64 // máximo de instrucciones a ejecutar (0 = sin límite)
+	// Presupuesto acumulado de reservas: suma los bytes aproximados de todo
+	// lo que el script crea durante la ejecución y nunca descuenta lo que
+	// deja de usarse, así un ciclo largo lo agota aunque use poca memoria
+	// a la vez (0 = sin límite).
+	MemoryLimit int64
+
+	Stdout io.Writer // salida de puts y print (nil = os.Stdout)
+	Stdin  io.Reader // entrada de input y readline (nil = os.Stdin)
+}
+
+// Cada vm crea su propio buffer sobre Stdin. Para que varias vms lean la
+// misma entrada sin perder lo que otra dejó en el buffer hay que pasarles
+// el mismo *bufio.Reader, como hacen la REPL y el intérprete.
+
+// Devuelve la configuración por defecto
+func DefaultConfig() Config {
+	return Config{
+		StackSize:  STACK_SIZE,
+		MaxFrames:  MAX_FRAMES,
+		MaxGlobals: GLOBAL_SIZE,
+		Builtins:   defaultBuiltins,
+	}
+}
+
 type VM struct {
 	objectPool  []object.Object // la lista de literales constantes
 	stack       []object.Object // la pila de objetos
@@ -24,14 +167,65 @@ type VM struct {
 	frames      []*Frame        // array de Frames
 	curFrame    *Frame
 	framesIndex int
+	builtins    *object.BuiltinRegistry // builtins disponibles para OpGetBuiltin
+	config      Config
+	executed    int64               // instrucciones ejecutadas por esta vm
+	allocated   int64               // bytes aproximados reservados por esta vm
+	execContext *object.ExecContext // flujos que reciben los builtins
 }
 
 // Crea la máquina virtual
 func New(bytecode *compiler.ByteCode) *VM {
+	return NewWithConfig(bytecode, nil, DefaultConfig())
+}
+
+// Crea la máquina virtual con la tabla de símbolos
+func NewWithGlobalsStore(bytecode *compiler.ByteCode, s []object.Object) *VM {
+	return NewWithConfig(bytecode, s, DefaultConfig())
+}
+
+// Crea la máquina virtual con la tabla de símbolos y un registro de builtins propio.
+// El registro debe ser el mismo que usó el compilador.
+func NewWithBuiltins(bytecode *compiler.ByteCode, s []object.Object, builtins *object.BuiltinRegistry) *VM {
+	config := DefaultConfig()
+	config.Builtins = builtins
+
+	return NewWithConfig(bytecode, s, config)
+}
+
+// Crea la máquina virtual con los globales y límites indicados.
+// Los campos en cero de `config` toman el valor por defecto.
+func NewWithConfig(bytecode *compiler.ByteCode, s []object.Object, config Config) *VM {
+	defaults := DefaultConfig()
+	if config.StackSize <= 0 {
+		config.StackSize = defaults.StackSize
+	}
+	if config.MaxFrames <= 0 {
+		config.MaxFrames = defaults.MaxFrames
+	}
+	if config.MaxGlobals <= 0 {
+		config.MaxGlobals = defaults.MaxGlobals
+	}
+	if config.Builtins == nil {
+		config.Builtins = defaults.Builtins
+	}
+	if config.Stdout == nil {
+		config.Stdout = os.Stdout
+	}
+	if config.Stdin == nil {
+		config.Stdin = os.Stdin
+	}
+	// reutilizamos el buffer si ya nos dieron uno para no perder datos entre vms
+	stdin, ok := config.Stdin.(*bufio.Reader)
+	if !ok {
+		stdin = bufio.NewReader(config.Stdin)
+	}
+
 	// Esta es digamos la función principal
 	// la máquina virtual creerá que siempre opera sobre frames
 	mainFunction := &object.CompiledFunction{
 		Instructions: bytecode.Instructions,
+		NumLocals:    bytecode.NumLocals,
 	}
 	mainClosure := &object.Closure{Fn: mainFunction}
 
@@ -44,33 +238,80 @@ func New(bytecode *compiler.ByteCode) *VM {
 
 	vm := &VM{
 		objectPool:  bytecode.ObjectPool,
-		stack:       make([]object.Object, STACK_SIZE),
-		sp:          0,
-		globals:     make([]object.Object, GLOBAL_SIZE),
+		stack:       make([]object.Object, config.StackSize),
+		sp:          bytecode.NumLocals, // las locales de los bloques del nivel superior
+		globals:     s,
 		frames:      frames,
 		curFrame:    mainFrame,
 		framesIndex: 0,
+		builtins:    config.Builtins,
+		config:      config,
+		execContext: &object.ExecContext{Out: config.Stdout, In: stdin},
 	}
 
 	return vm
 }
 
-// Crea la máquina virtual con la tabla de símbolos
-func NewWithGlobalsStore(bytecode *compiler.ByteCode, s []object.Object) *VM {
-	vm := New(bytecode)
-	vm.globals = s
+// Devuelve el array de globales. Puede ser distinto al recibido
+// en el constructor si la vm tuvo que hacerlo crecer.
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
 
-	return vm
+// Devuelve el total de bytes aproximados que ha reservado el script,
+// incluidos los objetos que ya no se usan
+func (vm *VM) Allocated() int64 {
+	return vm.allocated
+}
+
+// contabiliza una reserva de memoria antes de hacerla
+func (vm *VM) allocate(size int64) error {
+	limit := vm.config.MemoryLimit
+	if limit > 0 && vm.allocated+size > limit {
+		return &MemoryLimitError{Limit: limit, Allocated: vm.allocated, Requested: size}
+	}
+	vm.allocated += size
+	return nil
+}
+
+// guarda un global haciendo crecer el array si hace falta
+func (vm *VM) setGlobal(index int, obj object.Object) error {
+	if index < 0 {
+		return invalidBytecode("invalid global index %d", index)
+	}
+	if index >= vm.config.MaxGlobals {
+		return runtimeError(LimitExceeded, "too many globals: limit is %d", vm.config.MaxGlobals)
+	}
+	if index >= len(vm.globals) {
+		// duplicamos la capacidad para no crecer en cada definición
+		size := 2*len(vm.globals) + 8
+		if size <= index {
+			size = index + 1
+		}
+		if size > vm.config.MaxGlobals {
+			size = vm.config.MaxGlobals
+		}
+		globals := make([]object.Object, size)
+		copy(globals, vm.globals)
+		vm.globals = globals
+	}
+	vm.globals[index] = obj
+
+	return nil
 }
 
 // agrega un nuevo frame
-func (vm *VM) loadFrame(newFrame *Frame) {
+func (vm *VM) loadFrame(newFrame *Frame) error {
+	if len(vm.frames) >= vm.config.MaxFrames {
+		return runtimeError(StackOverflow, "stack overflow: maximum call depth of %d frames exceeded", vm.config.MaxFrames)
+	}
 	// ampliamos el array de frames
 	vm.frames = append(vm.frames, newFrame)
 	// incrementamos el contador de frames
 	vm.framesIndex += 1
 	// actualizamos el frame actual
 	vm.curFrame = vm.frames[vm.framesIndex]
+	return nil
 }
 
 // elimina el frame
@@ -88,7 +329,34 @@ func (vm *VM) unloadFrame() *Frame {
 
 // Comienza el ciclo Fetch-Decode-Execute
 func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// Igual que Run pero se detiene con un *CancelledError cuando el contexto
+// termina, o con ErrBudgetExceeded si se agota el presupuesto de instrucciones.
+// Un pánico durante la ejecución se devuelve como *InternalError.
+func (vm *VM) RunContext(ctx context.Context) (err error) {
+	defer vm.recoverPanic(&err)
+
+	if err := ctx.Err(); err != nil {
+		return &CancelledError{Cause: err}
+	}
+	done := ctx.Done()
+	budget := vm.config.InstructionBudget
+
 	for vm.curFrame.ip < len(vm.curFrame.cl.Fn.Instructions)-1 {
+		vm.executed += 1
+		if budget > 0 && vm.executed > budget {
+			return ErrBudgetExceeded
+		}
+		if done != nil && vm.executed%CONTEXT_CHECK_INTERVAL == 0 {
+			select {
+			case <-done:
+				return &CancelledError{Cause: ctx.Err()}
+			default:
+			}
+		}
+
 		vm.curFrame.ip += 1
 		// Obtener la instrucción a ejecutar
 		instruction := vm.curFrame.cl.Fn.Instructions[vm.curFrame.ip]
@@ -98,172 +366,267 @@ func (vm *VM) Run() error {
 			// Agregar una constante a la pila, necesitamos su índice entonces
 			// lo tomamos de la lista de objetos y usamos el campo instruction.index
 			index := instruction.Position
-			obj := vm.objectPool[index]
-			err := vm.push(obj)
+			if index < 0 || index >= len(vm.objectPool) {
+				return invalidBytecode("invalid constant index %d", index)
+			}
+			err := vm.push(vm.objectPool[index])
 			if err != nil {
 				return err
 			}
 
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpLess, code.OpLessEq, code.OpGreater, code.OpGreaterEq, code.OpEqual, code.OpNotEq, code.OpAnd, code.OpOr:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow, code.OpFloorDiv,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight, code.OpLess, code.OpLessEq, code.OpGreater, code.OpGreaterEq, code.OpEqual, code.OpNotEq, code.OpAnd, code.OpOr:
 			err := vm.executeBinaryOperation(instruction.OpCode)
 			if err != nil {
 				return err
 			}
 		case code.OpTrue, code.OpFalse:
+			var err error
 			if instruction.OpCode == code.OpTrue {
-				vm.push(TRUE)
+				err = vm.push(TRUE)
 			} else {
-				vm.push(FALSE)
+				err = vm.push(FALSE)
+			}
+			if err != nil {
+				return err
 			}
-		case code.OpNegBool, code.OpNegInt:
+		case code.OpNegBool, code.OpNegInt, code.OpBitNot:
 			err := vm.executeUnaryOperation(instruction.OpCode)
 			if err != nil {
 				return err
 			}
 		case code.OpNull:
-			vm.push(NULL)
+			err := vm.push(NULL)
+			if err != nil {
+				return err
+			}
 
 		case code.OpJumpNotTrue:
-			// TODO: validar el tipo de dato antes.
-			if !vm.pop().(*object.Boolean).Value {
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			condition, ok := obj.(*object.Boolean)
+			if !ok {
+				return runtimeError(TypeMismatch, "non-boolean condition: %s", obj.Type())
+			}
+			if !condition.Value {
 				// saltamos a donde nos indique OpJumpNotTrue
-				vm.curFrame.ip = instruction.Position - 1 // le resto 1 para que comience exactamente en el número correcto.
+				err := vm.jump(instruction.Position)
+				if err != nil {
+					return err
+				}
+			}
+		case code.OpJumpNull:
+			// el null se queda en la pila como valor de la cadena
+			if vm.sp <= 0 {
+				return ErrStackUnderflow
+			}
+			if _, ok := vm.stack[vm.sp-1].(*object.Null); ok {
+				err := vm.jump(instruction.Position)
+				if err != nil {
+					return err
+				}
+			}
+		case code.OpJumpNotNull:
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			if _, ok := obj.(*object.Null); !ok {
+				// el valor vuelve a la pila como resultado de `??`
+				err = vm.push(obj)
+				if err != nil {
+					return err
+				}
+				err = vm.jump(instruction.Position)
+				if err != nil {
+					return err
+				}
 			}
 		case code.OpJump:
 			// saltamos sin preguntar al índice
-			vm.curFrame.ip = instruction.Position - 1
+			err := vm.jump(instruction.Position)
+			if err != nil {
+				return err
+			}
 
 		case code.OpPop:
-			vm.pop()
+			_, err := vm.pop()
+			if err != nil {
+				return err
+			}
 
 		case code.OpSetGlobal:
 			// obtenemos el índice que nos dió el compilador
 			globalIndex := instruction.Position
 			// y por supuesto lo enlazamos con el último elemento de la pila
 			// se supone que la sentencia LET lo ha mandado a meter antes en la pila.
-			vm.globals[globalIndex] = vm.pop()
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			err = vm.setGlobal(globalIndex, obj)
+			if err != nil {
+				return err
+			}
 
 		case code.OpGetGlobal:
 			// obtenemos el índice del identificador
 			globalIndex := instruction.Position
+			if globalIndex < 0 {
+				return invalidBytecode("invalid global index %d", globalIndex)
+			}
+			// un global que todavía no ha crecido en el array vale null
+			var obj object.Object = NULL
+			if globalIndex < len(vm.globals) && vm.globals[globalIndex] != nil {
+				obj = vm.globals[globalIndex]
+			}
 			// empujamos el objeto en la pila
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.push(obj)
 			if err != nil {
 				return err
 			}
 
 		case code.OpSetLocal:
 			// obtenemos el índice
-			localIndex := instruction.Position
-			frame := vm.curFrame
-			vm.stack[frame.basePointer+localIndex] = vm.pop()
+			slot, err := vm.localSlot(instruction.Position)
+			if err != nil {
+				return err
+			}
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			vm.stack[slot] = obj
 
 		case code.OpGetLocal:
 			// obtenemos el índice
-			localIndex := instruction.Position
-			frame := vm.curFrame
-
+			slot, err := vm.localSlot(instruction.Position)
+			if err != nil {
+				return err
+			}
+			obj := vm.stack[slot]
+			if obj == nil {
+				obj = NULL
+			}
 			// enviamos el valor a la pila
-			err := vm.push(vm.stack[frame.basePointer+localIndex])
+			err = vm.push(obj)
 			if err != nil {
 				return err
 			}
 
 		case code.OpArray:
+			// el índice es el número de elementos que están en la pila
 			size := instruction.Position
-			arrayObj := &object.Array{
-				Elements: []object.Object{},
+			if size < 0 || size > vm.sp {
+				return ErrStackUnderflow
 			}
-
-			if size == 0 {
-				arrayObj.Elements = append(arrayObj.Elements, NULL)
-			} else {
-				for i := 0; i <= size; i++ {
-					arrayObj.Elements = append(arrayObj.Elements, vm.pop())
-				}
+			err := vm.allocate(object.ArraySize(size))
+			if err != nil {
+				return err
 			}
+			// copiamos los elementos en el orden en que se compilaron
+			elements := make([]object.Object, size)
+			copy(elements, vm.stack[vm.sp-size:vm.sp])
+			vm.sp -= size
+
 			// agregamos el array
-			err := vm.push(arrayObj)
+			err = vm.push(&object.Array{Elements: elements})
 			if err != nil {
 				return err
 			}
 
 		case code.OpHash:
-			size := instruction.Position // doble por que son tuplas (key->value)
+			// el índice es el número de pares (key->value) que están en la pila
+			size := instruction.Position
+			if size < 0 || 2*size > vm.sp {
+				return ErrStackUnderflow
+			}
 			// creamos el objeto hash
 			hashObj := &object.Hash{
-				Pairs: make(map[string]object.Object),
+				Pairs: make(map[string]object.Object, size),
 			}
-			if size == 0 {
-				vm.push(NULL)
-			} else {
-				for i := 0; i <= size; i++ {
-					key := vm.pop().Inspect()
-					value := vm.pop()
-					hashObj.Pairs[key] = value
+			for i := 0; i < size; i++ {
+				key, err := vm.pop()
+				if err != nil {
+					return err
+				}
+				value, err := vm.pop()
+				if err != nil {
+					return err
 				}
+				hashObj.Pairs[key.Inspect()] = value
+			}
+			err := vm.allocate(object.SizeOf(hashObj))
+			if err != nil {
+				return err
 			}
-			err := vm.push(hashObj)
+			err = vm.push(hashObj)
 			if err != nil {
 				return err
 			}
 
 		case code.OpAccess:
-			// recuperamos el objeto que hace de índice
-			objIndex := vm.pop()
-
-			// obtenemos el objeto collection de la pila
-			objCollection := vm.pop()
-			// hacemos las validaciones
-			switch objCollection.Type() {
-			case object.ARRAY_OBJ:
-				// el objeto que sirve de índice debe ser numérico
-				if objIndex.Type() != object.INTEGER_OBJ {
-					return fmt.Errorf("invalid subscript data type for array access %s", objIndex.Type())
-				}
-				// convertimos y enviamos a la pila el elemento del array
-				arrayObj := objCollection.(*object.Array)
-				index := int(objIndex.(*object.Integer).Value)
+			err := vm.executeAccess()
+			if err != nil {
+				return err
+			}
 
-				if index < 0 || index >= len(arrayObj.Elements) {
-					return fmt.Errorf("index out of range")
-				}
+		case code.OpAccessKeep:
+			// duplicamos la colección y el índice antes de acceder
+			if vm.sp < 2 {
+				return ErrStackUnderflow
+			}
+			collection, index := vm.stack[vm.sp-2], vm.stack[vm.sp-1]
+			err := vm.push(collection)
+			if err != nil {
+				return err
+			}
+			err = vm.push(index)
+			if err != nil {
+				return err
+			}
+			err = vm.executeAccess()
+			if err != nil {
+				return err
+			}
 
-				vm.push(arrayObj.Elements[index])
+		case code.OpSlice:
+			err := vm.executeSlice()
+			if err != nil {
+				return err
+			}
 
-			case object.HASH_OBJ:
-				// el objeto que sirve de índice debe ser string
-				if objIndex.Type() != object.STRING_OBJ {
-					return fmt.Errorf("invalid subscript data type for array access %s", objIndex.Type())
-				}
-				// convertimos y enviamos a la pila el elemento del diccionario
-				hashObj := objCollection.(*object.Hash)
-				key := objIndex.(*object.String).Value
-				if objValue, ok := hashObj.Pairs[key]; ok {
-					err := vm.push(objValue)
-					if err != nil {
-						return err
-					}
-				} else {
-					err := vm.push(NULL)
-					if err != nil {
-						return err
-					}
-				}
-			case object.STRING_OBJ:
-				// el objeto que sirve de índice debe ser numérico
-				if objIndex.Type() != object.INTEGER_OBJ {
-					return fmt.Errorf("invalid subscript data type for array access %s", objIndex.Type())
-				}
-				stringObj := objCollection.(*object.String)
-				index := int(objIndex.(*object.Integer).Value)
+		case code.OpSetIndex:
+			err := vm.executeSetIndex()
+			if err != nil {
+				return err
+			}
 
-				if index < 0 || index >= len(stringObj.Value) {
-					return fmt.Errorf("index out of range")
-				}
-				// nuevo string truncado
-				newStrObj := &object.String{Value: string(stringObj.Value[index])}
-				vm.push(newStrObj)
+		case code.OpIterator:
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			iterable, ok := obj.(object.Iterable)
+			if !ok {
+				return runtimeError(TypeMismatch, "cannot iterate over %s", obj.Type())
+			}
+			iterator := iterable.Iterator()
+			err = vm.allocate(object.SizeOf(iterator))
+			if err != nil {
+				return err
+			}
+			err = vm.push(iterator)
+			if err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			err := vm.executeIterNext(instruction.Position)
+			if err != nil {
+				return err
 			}
 
 		case code.OpCall:
@@ -275,44 +638,34 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-			// obtenemos la función
-			//peekObj := vm.peek()
-			// ahora tenemos que restar el número de argumentos
-			// peekObj := vm.stack[vm.sp-1-numArgs]
-			// callee, ok := peekObj.(*object.CompiledFunction)
-			// if !ok {
-			// 	return fmt.Errorf("calling non-function")
-			// }
-			// creamos un frame para la función
-			//frame := NewFrame(callee, vm.sp) // pasamos el puntero de la pila
-			// cargamos el frame en la vm.
-			//vm.loadFrame(frame)
-			// creamos un espacio en la pila para
-			// almacenar las variables locales
-			//vm.sp = frame.basePointer + callee.NumLocals
-
 		case code.OpReturnValue:
-			returnValue := vm.pop() // obtiene el valor a retornar
+			returnValue, err := vm.pop() // obtiene el valor a retornar
+			if err != nil {
+				return err
+			}
+			// un return fuera de una función termina el programa
+			// dejando su valor como el último extraído de la pila
+			if vm.framesIndex == 0 {
+				return nil
+			}
 
 			frame := vm.unloadFrame() // abandona el frame actual
 			// restauramos el puntero de la pila para recuperar la región reservada por el frame.
 			vm.sp = frame.basePointer - 1 // -1 para que se coma también el frame ejecutado
 
-			//vm.pop() // quita la función de la pila
-
-			err := vm.push(returnValue) // sube el valor a retornar por el frame anterior
+			err = vm.push(returnValue) // sube el valor a retornar por el frame anterior
 			if err != nil {
 				return err
 			}
 
 		case code.OpReturn:
+			if vm.framesIndex == 0 {
+				return nil
+			}
 			// salir del frame actual
 			frame := vm.unloadFrame()
 			vm.sp = frame.basePointer - 1 // para que se coma también el frame de la función ejecutada.
 
-			// eliminar el frame de la función con pop()
-			//vm.pop()
-
 			err := vm.push(NULL)
 			if err != nil {
 				return err
@@ -321,10 +674,13 @@ func (vm *VM) Run() error {
 		case code.OpGetBuiltin:
 			// obtenemos el índice del builtin
 			builtinIndex := instruction.Position
-			// obtenemos el builtin desde la lista de builtins
-			definition := object.Builtins[builtinIndex]
+			// obtenemos el builtin desde el registro de la vm
+			builtin, ok := vm.builtins.Get(builtinIndex)
+			if !ok {
+				return invalidBytecode("undefined builtin %d", builtinIndex)
+			}
 
-			err := vm.push(definition.Builtin)
+			err := vm.push(builtin)
 			if err != nil {
 				return err
 			}
@@ -342,20 +698,195 @@ func (vm *VM) Run() error {
 			freeIndex := instruction.Position
 
 			currentClosure := vm.curFrame.cl
+			if freeIndex < 0 || freeIndex >= len(currentClosure.Free) {
+				return invalidBytecode("invalid free variable index %d", freeIndex)
+			}
+			obj := currentClosure.Free[freeIndex]
+			// las variables capturadas viven en una celda; el nombre de
+			// una función exterior es la closure misma
+			if cell, ok := obj.(*object.Cell); ok {
+				obj = cell.Value
+			}
+			err := vm.push(obj)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetFree:
+			freeIndex := instruction.Position
+
+			currentClosure := vm.curFrame.cl
+			if freeIndex < 0 || freeIndex >= len(currentClosure.Free) {
+				return invalidBytecode("invalid free variable index %d", freeIndex)
+			}
+			cell, ok := currentClosure.Free[freeIndex].(*object.Cell)
+			if !ok {
+				return invalidBytecode("free variable %d is not a cell", freeIndex)
+			}
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			cell.Value = obj
+
+		case code.OpCaptureFree:
+			freeIndex := instruction.Position
+
+			currentClosure := vm.curFrame.cl
+			if freeIndex < 0 || freeIndex >= len(currentClosure.Free) {
+				return invalidBytecode("invalid free variable index %d", freeIndex)
+			}
+			// la celda se pasa tal cual para que ambas closures la compartan
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
 				return err
 			}
 
+		case code.OpNewCell:
+			slot, err := vm.localSlot(instruction.Position)
+			if err != nil {
+				return err
+			}
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			err = vm.newCell(slot, obj)
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetCell:
+			cell, err := vm.localCell(instruction.Position)
+			if err != nil {
+				return err
+			}
+			err = vm.push(cell.Value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetCell:
+			cell, err := vm.localCell(instruction.Position)
+			if err != nil {
+				return err
+			}
+			obj, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			cell.Value = obj
+
+		case code.OpCurClosure:
+			err := vm.push(vm.curFrame.cl)
+			if err != nil {
+				return err
+			}
+
+		default:
+			return invalidBytecode("unknown opcode %d", instruction.OpCode)
+		}
+	}
+	return nil
+}
+
+// convierte un pánico en un *InternalError con la información del frame actual
+func (vm *VM) recoverPanic(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	internal := &InternalError{Cause: r, Depth: vm.framesIndex}
+	if frame := vm.curFrame; frame != nil {
+		internal.IP = frame.ip
+		if frame.ip >= 0 && frame.ip < len(frame.cl.Fn.Instructions) {
+			internal.OpCode = code.OpCodeToString(frame.cl.Fn.Instructions[frame.ip].OpCode)
 		}
 	}
+	*err = internal
+}
+
+// mueve el ip del frame actual a la instrucción indicada
+func (vm *VM) jump(target int) error {
+	if target < 0 || target > len(vm.curFrame.cl.Fn.Instructions) {
+		return invalidBytecode("invalid jump target %d", target)
+	}
+	// le resto 1 para que comience exactamente en el número correcto.
+	vm.curFrame.ip = target - 1
 	return nil
 }
 
+// devuelve la posición en la pila de una variable local del frame actual
+func (vm *VM) localSlot(index int) (int, error) {
+	slot := vm.curFrame.basePointer + index
+	if index < 0 || slot >= len(vm.stack) {
+		return 0, invalidBytecode("invalid local index %d", index)
+	}
+	return slot, nil
+}
+
+// devuelve la celda guardada en una variable local del frame actual
+func (vm *VM) localCell(index int) (*object.Cell, error) {
+	slot, err := vm.localSlot(index)
+	if err != nil {
+		return nil, err
+	}
+	cell, ok := vm.stack[slot].(*object.Cell)
+	if !ok {
+		return nil, invalidBytecode("local %d is not a cell", index)
+	}
+	return cell, nil
+}
+
+// guarda el valor en una celda nueva en la posición indicada de la pila
+func (vm *VM) newCell(slot int, obj object.Object) error {
+	cell := &object.Cell{Value: obj}
+	err := vm.allocate(object.SizeOf(cell))
+	if err != nil {
+		return err
+	}
+	vm.stack[slot] = cell
+	return nil
+}
+
+// Invoca una función (closure o builtin) desde Go y devuelve su resultado.
+// Se usa para llamar funciones del script desde el programa anfitrión.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return vm.CallContext(context.Background(), fn, args...)
+}
+
+// Igual que Call pero respetando la cancelación del contexto
+func (vm *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (result object.Object, err error) {
+	defer vm.recoverPanic(&err)
+
+	err = vm.push(fn)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		err := vm.push(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = vm.executeCall(len(args))
+	if err != nil {
+		return nil, err
+	}
+	// ejecutamos el frame de la función hasta que retorne al frame principal
+	err = vm.RunContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.pop()
+}
+
 // Agrega un objeto en la pila
 func (vm *VM) push(obj object.Object) error {
-	if vm.sp >= STACK_SIZE {
-		return fmt.Errorf("stack overflow")
+	if vm.sp >= len(vm.stack) {
+		return runtimeError(StackOverflow, "stack overflow")
 	}
 	vm.stack[vm.sp] = obj
 	vm.sp += 1 // incrementa el puntero
@@ -364,10 +895,21 @@ func (vm *VM) push(obj object.Object) error {
 
 // Agrega un closure en la pila
 func (vm *VM) pushClosure(index int, numFree int) error {
+	if index < 0 || index >= len(vm.objectPool) {
+		return invalidBytecode("invalid constant index %d", index)
+	}
 	constant := vm.objectPool[index]
 	function, ok := constant.(*object.CompiledFunction)
 	if !ok {
-		return fmt.Errorf("not a function: %+v", constant)
+		return invalidBytecode("not a function: %+v", constant)
+	}
+	if numFree < 0 || numFree > vm.sp {
+		return ErrStackUnderflow
+	}
+
+	err := vm.allocate(object.ClosureSize(numFree))
+	if err != nil {
+		return err
 	}
 
 	free := make([]object.Object, numFree)
@@ -382,10 +924,13 @@ func (vm *VM) pushClosure(index int, numFree int) error {
 }
 
 // Quita un elemento de la pila
-func (vm *VM) pop() object.Object {
+func (vm *VM) pop() (object.Object, error) {
+	if vm.sp <= 0 {
+		return nil, ErrStackUnderflow
+	}
 	obj := vm.stack[vm.sp-1]
 	vm.sp -= 1 // decrementamos la pila
-	return obj
+	return obj, nil
 }
 
 // Devuelve el último elemento de la pila
@@ -397,40 +942,50 @@ func (vm *VM) StackTop() object.Object {
 }
 
 func (vm *VM) LastPoppedStackElem() object.Object {
+	if vm.sp >= len(vm.stack) {
+		return nil
+	}
 	return vm.stack[vm.sp]
 }
 
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	// comparar el número de parámetros y argumentos
 	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+		return runtimeError(WrongArity, "wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
 	}
 	// creamos el nuevo frame para la función
 	newFrame := NewFrame(cl, vm.sp-numArgs)
+	if newFrame.basePointer+cl.Fn.NumLocals >= len(vm.stack) {
+		return runtimeError(StackOverflow, "stack overflow")
+	}
 	// cargamos el nuevo frame en la máquina virtual
-	vm.loadFrame(newFrame)
+	err := vm.loadFrame(newFrame)
+	if err != nil {
+		return err
+	}
 	// creamos el "hueco" en la pila para las variables locales y argumentos
 	vm.sp = newFrame.basePointer + cl.Fn.NumLocals
 
+	// los parámetros capturados por una closure se guardan en una celda
+	for _, index := range cl.Fn.CellParameters {
+		if index < 0 || index >= numArgs {
+			return invalidBytecode("invalid parameter index %d", index)
+		}
+		slot := newFrame.basePointer + index
+		err := vm.newCell(slot, vm.stack[slot])
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// func (vm *VM) callFunction(funObj *object.CompiledFunction, numArgs int) error {
-// 	// comparar el número de parámetros y argumentos
-// 	if numArgs != funObj.NumParameters {
-// 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", funObj.NumParameters, numArgs)
-// 	}
-// 	// creamos el nuevo frame para la función
-// 	newFrame := NewFrame(funObj, vm.sp-numArgs)
-// 	// cargamos el nuevo frame en la máquina virtual
-// 	vm.loadFrame(newFrame)
-// 	// creamos el "hueco" en la pila para las variables locales y argumentos
-// 	vm.sp = newFrame.basePointer + funObj.NumLocals
-
-// 	return nil
-// }
-
 func (vm *VM) executeCall(numArgs int) error {
+	// la función y sus argumentos tienen que estar en la pila
+	if numArgs < 0 || vm.sp-1-numArgs < 0 {
+		return ErrStackUnderflow
+	}
 	callee := vm.stack[vm.sp-1-numArgs]
 	switch callee := callee.(type) {
 	case *object.Closure:
@@ -438,154 +993,414 @@ func (vm *VM) executeCall(numArgs int) error {
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-function and non-built-in")
+		return runtimeError(NotCallable, "calling non-function and non-built-in")
 	}
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	if builtin.Arity != object.VARIADIC && numArgs != builtin.Arity {
+		return runtimeError(WrongArity, "wrong number of arguments for %s: want=%d, got=%d", builtin.Name, builtin.Arity, numArgs)
+	}
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	result := builtin.Fn(args...)
+	result := builtin.Fn(vm.execContext, args...)
+	// un builtin del anfitrión puede devolver nil o un puntero nil con tipo
+	if isNil(result) {
+		result = NULL
+	}
+	// los builtins que devuelven colecciones nuevas (push, rest, ...)
+	// se contabilizan al retornar; si devuelven un argumento no reservan nada.
+	if isAllocation(result, args) {
+		err := vm.allocate(object.SizeOf(result))
+		if err != nil {
+			return err
+		}
+	}
 	vm.sp = vm.sp - numArgs - 1 // eliminamos la región de los argumentos
-	if result != nil {
-		vm.push(result)
-	} else {
-		vm.push(NULL)
+	return vm.push(result)
+}
+
+// determina si el objeto es nil, incluso si es un puntero nil envuelto en la interfaz
+func isNil(obj object.Object) bool {
+	if obj == nil {
+		return true
 	}
-	return nil
+	value := reflect.ValueOf(obj)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
+
+// determina si el resultado de un builtin es un objeto recién creado:
+// no lo es si es uno de los argumentos o un elemento ya guardado en
+// un array recibido (first, last).
+func isAllocation(result object.Object, args []object.Object) bool {
+	switch result.(type) {
+	case *object.String, *object.Array, *object.Hash:
+	default:
+		return false
+	}
+	for _, arg := range args {
+		if arg == result {
+			return false
+		}
+		if array, ok := arg.(*object.Array); ok {
+			for _, element := range array.Elements {
+				if element == result {
+					return false
+				}
+			}
+		}
+	}
+	return true
 }
 
 /*
 * FUNCIONES HELPER PARA LA MÁQUINA VIRTUAL
  */
+// Ejecuta el acceso por índice a un array, hash o string
+func (vm *VM) executeAccess() error {
+	// recuperamos el objeto que hace de índice
+	objIndex, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	// obtenemos el objeto collection de la pila
+	objCollection, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	// hacemos las validaciones
+	switch collection := objCollection.(type) {
+	case *object.Array:
+		// el objeto que sirve de índice debe ser numérico
+		integer, ok := objIndex.(*object.Integer)
+		if !ok {
+			return runtimeError(TypeMismatch, "invalid subscript data type for array access %s", objIndex.Type())
+		}
+		// enviamos a la pila el elemento del array
+		index := integer.Value
+		if index < 0 || index >= int64(len(collection.Elements)) {
+			return runtimeError(IndexOutOfRange, "index out of range")
+		}
+		return vm.push(collection.Elements[index])
+
+	case *object.Hash:
+		// el objeto que sirve de índice debe ser string
+		key, ok := objIndex.(*object.String)
+		if !ok {
+			return runtimeError(TypeMismatch, "invalid subscript data type for array access %s", objIndex.Type())
+		}
+		// enviamos a la pila el elemento del diccionario
+		if objValue, ok := collection.Pairs[key.Value]; ok {
+			return vm.push(objValue)
+		}
+		return vm.push(NULL)
+
+	case *object.String:
+		// el objeto que sirve de índice debe ser numérico
+		integer, ok := objIndex.(*object.Integer)
+		if !ok {
+			return runtimeError(TypeMismatch, "invalid subscript data type for array access %s", objIndex.Type())
+		}
+		index := integer.Value
+		if index < 0 || index >= int64(len(collection.Value)) {
+			return runtimeError(IndexOutOfRange, "index out of range")
+		}
+		// nuevo string truncado
+		err := vm.allocate(object.StringSize(1))
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: string(collection.Value[index])})
+
+	default:
+		return runtimeError(TypeMismatch, "index operator not supported: %s", objCollection.Type())
+	}
+}
+
+// Avanza el iterador del tope de la pila dejando encima la clave y el valor.
+// Si ya no quedan elementos saca el iterador y salta a `end`.
+func (vm *VM) executeIterNext(end int) error {
+	if vm.sp <= 0 {
+		return ErrStackUnderflow
+	}
+	iterator, ok := vm.stack[vm.sp-1].(object.Iterator)
+	if !ok {
+		return invalidBytecode("not an iterator: %s", vm.stack[vm.sp-1].Type())
+	}
+
+	key, value, ok := iterator.Next()
+	if !ok {
+		vm.sp -= 1
+		return vm.jump(end)
+	}
+	err := vm.push(key)
+	if err != nil {
+		return err
+	}
+	return vm.push(value)
+}
+
+// Ejecuta collection[index] = value. Arrays y hashes se modifican en su
+// lugar, así que todas las variables que apuntan a ellos ven el cambio.
+func (vm *VM) executeSetIndex() error {
+	value, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	objIndex, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	objCollection, err := vm.pop()
+	if err != nil {
+		return err
+	}
+
+	switch collection := objCollection.(type) {
+	case *object.Array:
+		integer, ok := objIndex.(*object.Integer)
+		if !ok {
+			return runtimeError(TypeMismatch, "invalid subscript data type for array access %s", objIndex.Type())
+		}
+		index := integer.Value
+		if index < 0 || index >= int64(len(collection.Elements)) {
+			return runtimeError(IndexOutOfRange, "index out of range")
+		}
+		collection.Elements[index] = value
+
+	case *object.Hash:
+		key, ok := objIndex.(*object.String)
+		if !ok {
+			return runtimeError(TypeMismatch, "invalid subscript data type for array access %s", objIndex.Type())
+		}
+		// solo las claves nuevas reservan memoria
+		if _, exists := collection.Pairs[key.Value]; !exists {
+			err := vm.allocate(object.HashEntrySize(key.Value))
+			if err != nil {
+				return err
+			}
+		}
+		collection.Pairs[key.Value] = value
+
+	default:
+		return runtimeError(TypeMismatch, "index assignment not supported: %s", objCollection.Type())
+	}
+	// la asignación es una expresión cuyo valor es el asignado
+	return vm.push(value)
+}
+
+// Ejecuta collection[start:end] sobre un array o un string
+func (vm *VM) executeSlice() error {
+	end, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	start, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	objCollection, err := vm.pop()
+	if err != nil {
+		return err
+	}
+
+	switch collection := objCollection.(type) {
+	case *object.Array:
+		low, high, err := object.SliceBounds(len(collection.Elements), start, end)
+		if err != nil {
+			return runtimeError(TypeMismatch, "%s", err)
+		}
+		err = vm.allocate(object.ArraySize(high - low))
+		if err != nil {
+			return err
+		}
+		// copiamos para que el nuevo array no comparta elementos con el original
+		elements := make([]object.Object, high-low)
+		copy(elements, collection.Elements[low:high])
+		return vm.push(&object.Array{Elements: elements})
+
+	case *object.String:
+		low, high, err := object.SliceBounds(len(collection.Value), start, end)
+		if err != nil {
+			return runtimeError(TypeMismatch, "%s", err)
+		}
+		err = vm.allocate(object.StringSize(high - low))
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: collection.Value[low:high]})
+
+	default:
+		return runtimeError(TypeMismatch, "slice operator not supported: %s", objCollection.Type())
+	}
+}
+
 // Ejecuta una operación binaria
 func (vm *VM) executeBinaryOperation(op code.OpCode) error {
 	// Sumamos los 2 elementos de la pila y devolvemos su resultado
-	var right = vm.pop()
-	var left = vm.pop()
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
-		return vm.executeBinaryInteger(left, op, right)
-	} else if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
-		if op != code.OpAdd {
-			return fmt.Errorf("unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+	right, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	left, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	switch left := left.(type) {
+	case *object.Integer:
+		if right, ok := right.(*object.Integer); ok {
+			return vm.executeBinaryInteger(left, op, right)
+		}
+	case *object.String:
+		if right, ok := right.(*object.String); ok {
+			if op != code.OpAdd {
+				return runtimeError(TypeMismatch, "unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+			}
+			return vm.executeBinaryString(left, op, right)
+		}
+	case *object.Boolean:
+		if right, ok := right.(*object.Boolean); ok {
+			return vm.executeBinaryBoolean(left, op, right)
 		}
-		return vm.executeBinaryString(left, op, right)
-	} else if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
-		return vm.executeBinaryBoolean(left, op, right)
 	}
-	return fmt.Errorf("unsupported types for binary operation: %s %s", left.Type(), right.Type())
+	return runtimeError(TypeMismatch, "unsupported types for binary operation: %s %s", left.Type(), right.Type())
 }
 
 // Ejecuta una operación unaria
 func (vm *VM) executeUnaryOperation(op code.OpCode) error {
-	obj := vm.pop()
+	obj, err := vm.pop()
+	if err != nil {
+		return err
+	}
 	if op == code.OpNegBool {
-		if obj.Type() != object.BOOLEAN_OBJ {
-			return fmt.Errorf("invalid type for this operation %s", obj.Type())
-		}
-		if obj.(*object.Boolean).Value {
-			vm.push(FALSE)
-		} else {
-			vm.push(TRUE)
+		boolean, ok := obj.(*object.Boolean)
+		if !ok {
+			return runtimeError(TypeMismatch, "invalid type for this operation %s", obj.Type())
 		}
-	} else {
-		if obj.Type() != object.INTEGER_OBJ {
-			return fmt.Errorf("invalid type for this operation %s", obj.Type())
+		if boolean.Value {
+			return vm.push(FALSE)
 		}
-		vm.push(&object.Integer{Value: obj.(*object.Integer).Value * -1})
+		return vm.push(TRUE)
 	}
-	return nil
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		return runtimeError(TypeMismatch, "invalid type for this operation %s", obj.Type())
+	}
+	if op == code.OpBitNot {
+		return vm.push(&object.Integer{Value: ^integer.Value})
+	}
+	return vm.push(&object.Integer{Value: integer.Value * -1})
 }
 
 // Ejecuta una operación binaria con enteros
-func (vm *VM) executeBinaryInteger(left object.Object, op code.OpCode, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+func (vm *VM) executeBinaryInteger(left *object.Integer, op code.OpCode, right *object.Integer) error {
+	leftValue := left.Value
+	rightValue := right.Value
 	switch op {
 	case code.OpAdd:
-		vm.push(&object.Integer{Value: leftValue + rightValue})
+		return vm.push(&object.Integer{Value: leftValue + rightValue})
 	case code.OpSub:
-		vm.push(&object.Integer{Value: leftValue - rightValue})
+		return vm.push(&object.Integer{Value: leftValue - rightValue})
 	case code.OpMul:
-		vm.push(&object.Integer{Value: leftValue * rightValue})
+		return vm.push(&object.Integer{Value: leftValue * rightValue})
 	case code.OpDiv:
 		if rightValue == 0 {
-			return fmt.Errorf("division by zero")
+			return runtimeError(DivisionByZero, "division by zero")
 		}
-		vm.push(&object.Integer{Value: leftValue / rightValue})
-	case code.OpLess:
-		if leftValue < rightValue {
-			vm.push(TRUE)
-		} else {
-			vm.push(FALSE)
+		return vm.push(&object.Integer{Value: leftValue / rightValue})
+	case code.OpMod:
+		if rightValue == 0 {
+			return runtimeError(DivisionByZero, "division by zero")
 		}
-	case code.OpLessEq:
-		if leftValue <= rightValue {
-			vm.push(TRUE)
-		} else {
-			vm.push(FALSE)
+		return vm.push(&object.Integer{Value: object.FloorMod(leftValue, rightValue)})
+	case code.OpFloorDiv:
+		if rightValue == 0 {
+			return runtimeError(DivisionByZero, "division by zero")
 		}
-	case code.OpGreater:
-		if leftValue > rightValue {
-			vm.push(TRUE)
-		} else {
-			vm.push(FALSE)
+		return vm.push(&object.Integer{Value: object.FloorDiv(leftValue, rightValue)})
+	case code.OpPow:
+		if rightValue < 0 {
+			return runtimeError(InvalidOperand, "negative exponent: %d", rightValue)
 		}
-	case code.OpGreaterEq:
-		if leftValue >= rightValue {
-			vm.push(TRUE)
-		} else {
-			vm.push(FALSE)
+		value, ok := object.Power(leftValue, rightValue)
+		if !ok {
+			return runtimeError(IntegerOverflow, "integer overflow: %d ** %d", leftValue, rightValue)
 		}
-	case code.OpEqual:
-		if leftValue == rightValue {
-			vm.push(TRUE)
-		} else {
-			vm.push(FALSE)
+		return vm.push(&object.Integer{Value: value})
+	case code.OpBitAnd:
+		return vm.push(&object.Integer{Value: leftValue & rightValue})
+	case code.OpBitOr:
+		return vm.push(&object.Integer{Value: leftValue | rightValue})
+	case code.OpBitXor:
+		return vm.push(&object.Integer{Value: leftValue ^ rightValue})
+	case code.OpShiftLeft, code.OpShiftRight:
+		if rightValue < 0 {
+			return runtimeError(InvalidOperand, "negative shift count: %d", rightValue)
 		}
-	case code.OpNotEq:
-		if leftValue != rightValue {
-			vm.push(TRUE)
-		} else {
-			vm.push(FALSE)
+		// desde 64 bits se pierden todos: queda 0, o -1 si `>>` corre un negativo
+		if op == code.OpShiftLeft {
+			return vm.push(&object.Integer{Value: leftValue << rightValue})
 		}
-
+		return vm.push(&object.Integer{Value: leftValue >> rightValue})
+	case code.OpLess:
+		return vm.pushBoolean(leftValue < rightValue)
+	case code.OpLessEq:
+		return vm.pushBoolean(leftValue <= rightValue)
+	case code.OpGreater:
+		return vm.pushBoolean(leftValue > rightValue)
+	case code.OpGreaterEq:
+		return vm.pushBoolean(leftValue >= rightValue)
+	case code.OpEqual:
+		return vm.pushBoolean(leftValue == rightValue)
+	case code.OpNotEq:
+		return vm.pushBoolean(leftValue != rightValue)
 	default:
-		return fmt.Errorf("unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+		return runtimeError(TypeMismatch, "unsupported operator for binary operation: %s %s", left.Type(), right.Type())
 	}
-	return nil
+}
+
+// Agrega en la pila el Boolean que corresponde al valor nativo
+func (vm *VM) pushBoolean(value bool) error {
+	if value {
+		return vm.push(TRUE)
+	}
+	return vm.push(FALSE)
 }
 
 // Ejecuta una operación binaria con Strings (solo se soporta el operador '+')
-func (vm *VM) executeBinaryString(left object.Object, op code.OpCode, right object.Object) error {
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
+func (vm *VM) executeBinaryString(left *object.String, op code.OpCode, right *object.String) error {
+	leftVal := left.Value
+	rightVal := right.Value
 
 	switch op {
 	case code.OpAdd:
-		vm.push(&object.String{Value: string(leftVal + rightVal)})
+		// contabilizamos antes de concatenar para no reservar un string enorme
+		err := vm.allocate(object.StringSize(len(leftVal) + len(rightVal)))
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: string(leftVal + rightVal)})
 	default:
-		return fmt.Errorf("unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+		return runtimeError(TypeMismatch, "unsupported operator for binary operation: %s %s", left.Type(), right.Type())
 	}
-	return nil
 }
 
 // Ejecuta una operación bnaria con Booleans
-func (vm *VM) executeBinaryBoolean(left object.Object, op code.OpCode, right object.Object) error {
+func (vm *VM) executeBinaryBoolean(left *object.Boolean, op code.OpCode, right *object.Boolean) error {
 	if op == code.OpAnd || op == code.OpOr {
 		return vm.executeBinaryLogic(left, op, right)
 	}
 	if op != code.OpLess && op != code.OpLessEq && op != code.OpGreater && op != code.OpGreaterEq && op != code.OpEqual && op != code.OpNotEq {
-		return fmt.Errorf("unsupported operator for binary operation %s %s", left.Type(), right.Type())
+		return runtimeError(TypeMismatch, "unsupported operator for binary operation %s %s", left.Type(), right.Type())
 	}
 	// convertimos boolean a integer
 	leftInteger := &object.Integer{Value: 0}
 	rightInteger := &object.Integer{Value: 0}
 
-	if left.(*object.Boolean).Value {
+	if left.Value {
 		leftInteger.Value = 1
 	}
 
-	if right.(*object.Boolean).Value {
+	if right.Value {
 		rightInteger.Value = 1
 	}
 
@@ -593,24 +1408,13 @@ func (vm *VM) executeBinaryBoolean(left object.Object, op code.OpCode, right obj
 }
 
 // Ejecuta una operación binaria con Lógicos
-func (vm *VM) executeBinaryLogic(left object.Object, op code.OpCode, right object.Object) error {
-	leftBoolValue := left.(*object.Boolean).Value
-	rightBoolValue := right.(*object.Boolean).Value
-
+func (vm *VM) executeBinaryLogic(left *object.Boolean, op code.OpCode, right *object.Boolean) error {
 	switch op {
 	case code.OpAnd:
-		if leftBoolValue && rightBoolValue {
-			return vm.push(TRUE)
-		} else {
-			return vm.push(FALSE)
-		}
+		return vm.pushBoolean(left.Value && right.Value)
 	case code.OpOr:
-		if leftBoolValue || rightBoolValue {
-			return vm.push(TRUE)
-		} else {
-			return vm.push(FALSE)
-		}
+		return vm.pushBoolean(left.Value || right.Value)
 	default:
-		return fmt.Errorf("unsupported operator for binary operation: %s %s", left.Type(), right.Type())
+		return runtimeError(TypeMismatch, "unsupported operator for binary operation: %s %s", left.Type(), right.Type())
 	}
 }
@@ -0,0 +1,737 @@
+package vm
+
+import (
+	"MonkeyHabilis/ast"
+	"MonkeyHabilis/code"
+	"MonkeyHabilis/compiler"
+	"MonkeyHabilis/lexer"
+	"MonkeyHabilis/object"
+	"MonkeyHabilis/parser"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+// representa el valor null esperado
+type null struct{}
+
+func parse(t *testing.T, input string) *ast.ProgramNode {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.Program()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors)
+	}
+	return program
+}
+
+// compila y ejecuta el programa con la configuración indicada
+func run(t *testing.T, input string, config Config) (*VM, error) {
+	t.Helper()
+	comp := compiler.New()
+	err := comp.Compile(parse(t, input))
+	if err != nil {
+		t.Fatalf("%q: compiler error: %s", input, err)
+	}
+	machine := NewWithConfig(comp.GetByteCode(), nil, config)
+	return machine, machine.Run()
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Stdout = io.Discard
+
+	for _, tt := range tests {
+		machine, err := run(t, tt.input, config)
+		if err != nil {
+			t.Fatalf("%q: vm error: %s", tt.input, err)
+		}
+		// un programa completo no deja valores olvidados en la pila, solo
+		// las locales de sus bloques
+		if left := machine.sp - machine.frames[0].cl.Fn.NumLocals; left != 0 {
+			t.Errorf("%q: %d values left on the stack", tt.input, left)
+		}
+		testExpectedObject(t, tt.input, tt.expected, machine.LastPoppedStackElem())
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("%q: want integer %d, got=%#v", input, expected, actual)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("%q: want boolean %t, got=%#v", input, expected, actual)
+		}
+	case string:
+		str, ok := actual.(*object.String)
+		if !ok || str.Value != expected {
+			t.Errorf("%q: want string %q, got=%#v", input, expected, actual)
+		}
+	case null:
+		if actual != NULL {
+			t.Errorf("%q: want null, got=%#v", input, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("%q: want array, got=%#v", input, actual)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("%q: want %d elements, got=%s", input, len(expected), array.Inspect())
+			return
+		}
+		for i, element := range expected {
+			testExpectedObject(t, input, element, array.Elements[i])
+		}
+	case map[string]int:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("%q: want hash, got=%#v", input, actual)
+			return
+		}
+		if len(hash.Pairs) != len(expected) {
+			t.Errorf("%q: want %d pairs, got=%s", input, len(expected), hash.Inspect())
+			return
+		}
+		for key, value := range expected {
+			testExpectedObject(t, input, value, hash.Pairs[key])
+		}
+	default:
+		t.Fatalf("%q: unsupported expected type %T", input, expected)
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"4 * 3", 12},
+		{"7 / 2", 3},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-5", -5},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		// `/` trunca hacia cero, `~/` y `%` redondean hacia menos infinito
+		{"-7 / 2", -3},
+		{"-7 ~/ 2", -4},
+		{"7 ~/ -2", -4},
+		{"-7 % 2", 1},
+		{"7 % -2", -1},
+		{"-8 % 2", 0},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"0 ** 0", 1},
+		{"(-2) ** 63", -9223372036854775808},
+		{"1 + 2 * 3 ** 2 % 5", 4},
+		{"12 & 10", 8},
+		{"12 | 3", 15},
+		{"12 ^ 10", 6},
+		{"~0", -1},
+		{"~-8", 7},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 << 63", -9223372036854775808},
+		{"1 << 64", 0},
+		{"-1 >> 64", -1},
+		{"6 & 3 == 2", true},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 <= 1", true},
+		{"2 >= 3", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"true > false", true},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!!true", true},
+		{"true && false", false},
+		{"true || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"null", null{}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", null{}},
+		{"if ((if (false) { false } else { true })) { 10 } else { 20 }", 10},
+	}
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+	}
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key"`, "monkey"},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`"abc"[1]`, "b"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[7]", []int{7}},
+		{"len([])", 0},
+		{"len([[]])", 1},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1], [2, 3]][1][0]", 2},
+		{"{}", map[string]int{}},
+		{`{"a": 1}`, map[string]int{"a": 1}},
+		{`{}["a"]`, null{}},
+		{`{"a": 1, "b": 2}`, map[string]int{"a": 1, "b": 2}},
+		{`{"a": 1 + 1, "b": 2 * 3}["b"]`, 6},
+		{`{"a": 1, "b": 2}["c"]`, null{}},
+		// slices
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-3:-1]", []int{2, 3}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"[][0:5]", []int{}},
+		{`"monkey"[1:4]`, "onk"},
+		{`"monkey"[-3:]`, "key"},
+		{`"monkey"[:0]`, ""},
+		{"let a = [1, 2, 3]; let b = a[:]; len(b) + len(a)", 6},
+		// asignación por índice
+		{"let a = [1, 2, 3]; a[1] = 5; a", []int{1, 5, 3}},
+		{"let a = [1, 2, 3]; a[0] = a[1] = 7", 7},
+		{`let h = {"a": 1}; h["b"] = 2; h`, map[string]int{"a": 1, "b": 2}},
+		{`let h = {}; h["a"] = 1; h["a"] = h["a"] + 1; h["a"]`, 2},
+		// los arrays se comparten por referencia, los slices y push copian
+		{"let a = [1, 2]; let b = a; b[0] = 9; a", []int{9, 2}},
+		{"let a = [1, 2]; let b = a[:]; b[0] = 9; a", []int{1, 2}},
+		{"let a = [1, 2]; let b = push(a, 3); b[0] = 9; a", []int{1, 2}},
+		{"let set = fn(arr) { arr[0] = 0 }; let a = [1, 2]; set(a); a", []int{0, 2}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestForIn(t *testing.T) {
+	tests := []vmTestCase{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; } sum", 6},
+		{"let sum = 0; for (i, x in [10, 20]) { sum = sum + i * x; } sum", 20},
+		{`let keys = ""; for (k, v in {"b": 2, "a": 1}) { keys = keys + k; } keys`, "ab"},
+		{`let total = 0; for (v in {"b": 2, "a": 1}) { total = total + v; } total`, 3},
+		{`let out = ""; for (c in "abc") { out = c + out; } out`, "cba"},
+		{"let sum = 0; for (i in range(5)) { sum = sum + i; } sum", 10},
+		{"let sum = 0; for (i in range(10, 0, -3)) { sum = sum + i; } sum", 22},
+		{"let n = 0; for (i in range(3, 3)) { n = n + 1; } n", 0},
+		{"len(range(0, 10, 3))", 4},
+		// el iterador no copia el array: ve los cambios hechos durante el ciclo
+		{"let a = [1, 2, 3]; let sum = 0; for (i, x in a) { if (i == 0) { a[2] = 10 } sum = sum + x; } sum", 13},
+		// un return dentro del ciclo descarta el iterador
+		{"let find = fn(arr, n) { for (i, x in arr) { if (x == n) { return i; } } -1 }; find([5, 6, 7], 7) + find([1], 9)", 1},
+		{"let f = fn() { let s = 0; for (x in range(4)) { s = s + x; } s }; f()", 6},
+		{"for (x in []) { x }", null{}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestLoopControl(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { i = i + 1; } i", 5},
+		{"let i = 0; while (true) { if (i == 3) { break } i = i + 1; } i", 3},
+		{"let i = 0; let odd = 0; while (i < 6) { i = i + 1; if (i / 2 * 2 == i) { continue } odd = odd + i; } odd", 9},
+		{"let sum = 0; for (x in range(10)) { if (x == 4) { break } sum = sum + x; } sum", 6},
+		{"let sum = 0; for (x in range(6)) { if (x < 3) { continue } sum = sum + x; } sum", 12},
+		// el break solo sale del ciclo más interno
+		{"let n = 0; for (x in range(3)) { for (y in range(3)) { if (y == 1) { break } n = n + 1; } } n", 3},
+		// romper muchas veces un for-in no debe dejar iteradores en la pila
+		{"let n = 0; while (n < 5000) { for (x in [1, 2]) { break } n = n + 1; } n", 5000},
+		{"let f = fn() { let i = 0; while (true) { i = i + 1; if (i > 2) { return i } } }; f()", 3},
+		// break y continue dentro de una expresión sacan lo que ella ya apiló
+		{"let n = 0; while (n < 5000) { n = n + 1; let y = [1, if (true) { continue } else { 2 }]; } n", 5000},
+		{"for (x in [1,2,3]) { let r = [x, if (x == 2) { break } else { x }]; }", null{}},
+		{"let r = []; for (x in [1, 2, 3]) { r = [x, if (x == 2) { break } else { x }]; } r", []int{1, 1}},
+		{"let n = 0; while (n < 5000) { n = n + 1; for (x in [1]) { let y = [x, x, if (true) { break }]; } } n", 5000},
+		{"let f = fn() { let n = 0; while (n < 5000) { n = n + 1; let y = [n, if (n > 0) { continue }]; } n }; f()", 5000},
+		// un ciclo al final del programa no deja su condición como resultado
+		{"let i = 0; while (i < 3) { i = i + 1 }", null{}},
+		{"let i = 0; for (; i < 3; ) { i = i + 1 }", null{}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestForLoop(t *testing.T) {
+	tests := []vmTestCase{
+		{"let sum = 0; for (let i = 0; i < 5; i = i + 1) { sum = sum + i; } sum", 10},
+		{"let n = 0; for (; n < 3; ) { n = n + 1; } n", 3},
+		{"let i = 0; for (;;) { i = i + 1; if (i == 4) { break } } i", 4},
+		// continue ejecuta el step antes de volver a la condición
+		{"let s = 0; for (let i = 0; i < 6; i = i + 1) { if (i < 3) { continue } s = s + i; } s", 12},
+		// la i del ciclo no toca la global del mismo nombre
+		{"let i = 100; for (let i = 0; i < 3; i = i + 1) { } i", 100},
+		{"let f = fn(n) { let p = 1; for (let i = 1; i <= n; i = i + 1) { p = p * i; } p }; f(5)", 120},
+		// un let en el cuerpo define una variable del ciclo
+		{"let s = 0; for (let i = 0; i < 3; i = i + 1) { let s = 5; } s", 0},
+		{"let x = 10; let f = fn() { let x = x + 1; x }; f()", 11},
+		{"let a = 1; let b = a = 2; a + b", 4},
+		// dos ciclos seguidos comparten los índices locales
+		{"let f = fn() { let s = 0; for (let i = 0; i < 3; i = i + 1) { s = s + i; } for (let j = 10; j < 12; j = j + 1) { s = s + j; } s }; f()", 24},
+		{"let f = fn() { let g = 0; for (let i = 5; i < 6; i = i + 1) { g = fn() { i }; } for (let j = 9; j < 10; j = j + 1) { } g() }; f()", 6},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; if (true) { let x = 2; } x", 1},
+		{"let x = 1; let y = if (true) { let x = x + 10; x } else { 0 }; x + y", 12},
+		{"let x = 1; while (x < 3) { let y = x; x = y + 1; } x", 3},
+		{"let n = 0; for (i in range(3)) { let n = i; } n", 0},
+		// el bloque ve su propio let a partir de donde se define
+		{"let y = 0; let seen = []; for (x in [1, 2]) { seen = push(seen, y); let y = x; } seen", []int{0, 0}},
+		// dentro de una función un bloque reutiliza los índices del anterior
+		{"let f = fn() { if (true) { let a = 1; } let b = 2; if (true) { let c = 3; b + c } }; f()", 5},
+		{"let f = fn() { let r = []; for (x in [1, 2]) { let d = x * 2; r = push(r, fn() { d }); } r[0]() + r[1]() }; f()", 6},
+		// cada iteración define su propia x, también fuera de una función
+		{"let r = []; for (x in [1, 2]) { r = push(r, fn() { x }); } r[0]() + r[1]()", 3},
+		{"let fs = []; for (i in [1, 2, 3]) { fs = push(fs, fn() { i }) }; [fs[0](), fs[1](), fs[2]()]", []int{1, 2, 3}},
+		{"let f = fn() { let fs = []; for (i in [1, 2, 3]) { fs = push(fs, fn() { i }) }; fs }; let fs = f(); [fs[0](), fs[1](), fs[2]()]", []int{1, 2, 3}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConstants(t *testing.T) {
+	tests := []vmTestCase{
+		{`const a = 2; const s = "ab"; a * len(s)`, 4},
+		{"const a = [1, 2]; a[0] = 5; a", []int{5, 2}},
+		{"const a = 1; let f = fn() { let a = 2; a }; f() + a", 3},
+		{"let f = fn() { const n = 10; let g = fn() { n + 1 }; g() }; f()", 11},
+		{"let f = fn(x) { const y = x * 2; fn() { y } }; f(4)()", 8},
+		{"const a = 1; if (true) { const a = 2; a } else { 0 }", 2},
+		{"let s = 0; for (x in [1, 2, 3]) { const d = x * 2; s = s + d; } s", 12},
+	}
+	runVmTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { 5 + 10 }; f()", 15},
+		{"let one = fn() { 1 }; let two = fn() { 2 }; one() + two()", 3},
+		{"let f = fn() { return 99; 100 }; f()", 99},
+		{"let f = fn() { }; f()", null{}},
+		{"let sum = fn(a, b) { let c = a + b; c }; sum(1, 2) + sum(3, 4)", 10},
+		{"let f = fn() { 1 }; let g = fn() { f }; g()()", 1},
+		{"fn(a) { a * 2 }(21)", 42},
+	}
+	runVmTests(t, tests)
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let x = 1; [x++, x, x--, x]", []int{1, 2, 2, 1}},
+		{"let x = 1; x += x += 1", 3},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let f = fn() { let n = 0; for (let i = 0; i < 5; i++) { n += i; } n }; f()", 10},
+		// la closure actualiza su propia copia de la variable capturada
+		{"let counter = fn() { let c = 0; fn() { c++; c } }; let next = counter(); next(); next(); next()", 3},
+		{"let f = fn() { let c = 0; let inc = fn() { c += 1 }; inc(); inc(); c }; f()", 2},
+		{"let a = [1, 2]; a[1] *= 10; a", []int{1, 20}},
+		{`let h = {"n": 1}; h["n"]++; h["n"] += 5; h["n"]`, 7},
+		{"let a = [5]; [a[0]--, a[0]]", []int{5, 4}},
+		// `--` entre dos operandos es una resta de un negativo
+		{"5--3", 8},
+		{"let a = 5; let b = 2; [a--b, a, --b]", []int{7, 5, 2}},
+		// a[i()] evalúa i() una sola vez
+		{"let a = [0, 0]; let calls = 0; let i = fn() { calls++; 1 }; a[i()] += 3; a[i()]++; [a[1], calls]", []int{4, 2}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConditionalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"1 < 2 ? 10 : 20", 10},
+		{"1 > 2 ? 10 : 20", 20},
+		{"let n = 5; n < 0 ? -1 : n == 0 ? 0 : 1", 1},
+		{"let x = false ? 1 : 2; x * 10", 20},
+		{"null ?? 3", 3},
+		{"0 ?? 3", 0},
+		{"false ?? 3", false},
+		{`let h = {"a": 1}; h["b"] ?? h["a"]`, 1},
+		// el lado derecho de `??` no se evalúa si no hace falta
+		{"let n = 0; let f = fn() { n++ }; 1 ?? f(); null ?? f(); n", 1},
+		{`let h = {"user": {"name": "ana"}}; h?.user?.name`, "ana"},
+		{`let h = {}; h["user"]?.name`, null{}},
+		{`let h = {}; h["user"]?.name ?? "anon"`, "anon"},
+		{"let a = null; a?[0]", null{}},
+		{"let a = null; a?[0][1](2)", null{}},
+		{"let a = [[1, 2]]; a?[0][1]", 2},
+		{"let a = null; a?[1:]", null{}},
+		{"let a = [1, 2, 3]; a?[1:]", []int{2, 3}},
+		{"let f = fn(a) { a?[0] ?? -1 }; f(null) + f([5])", 4},
+	}
+	runVmTests(t, tests)
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3] |> len()", 3},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3)", 6},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"[1, 2] |> push(3) |> rest() |> last()", 3},
+		{"[1, 2] |> len() > 1 && [] |> len() == 0", true},
+		{"let double = fn(x) { x * 2 }; 1 + 2 |> double()", 6},
+	}
+	runVmTests(t, tests)
+}
+
+func TestArrowFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let add = (a, b) => a + b; add(2, 3)", 5},
+		{"let double = x => x * 2; double(4)", 8},
+		{"(() => 42)()", 42},
+		{"let adder = x => y => x + y; adder(1)(2)", 3},
+		{"let fact = n => n < 2 ? 1 : n * fact(n - 1); fact(10)", 3628800},
+		{"let apply = fn(f, x) { f(x) }; apply(x => x - 1, 10)", 9},
+		{"let n = 0; let inc = () => n += 1; inc(); inc(); n", 2},
+		{"let h = x => {\"v\": x}; h(1)[\"v\"]", 1},
+		{"let apply = fn(x, f) { f(x) }; 3 |> apply(x => x * x)", 9},
+	}
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newClosure = fn(a) { fn() { a } }; let closure = newClosure(99); closure()", 99},
+		{"let newAdder = fn(a, b) { let c = a + b; fn(d) { c + d } }; let adder = newAdder(1, 2); adder(8)", 11},
+		{"let outer = fn() { let a = 1; fn() { let b = 2; fn() { a + b } } }; outer()()()", 3},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", 610},
+		// la función y sus closures comparten las variables capturadas
+		{"fn() { let x = 1; let g = fn() { x += 1 }; g(); x }()", 2},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn(a) { let set = fn(v) { a = v }; let get = fn() { fn() { a } }; set(5); get()() }; f(1)", 5},
+		{"let f = fn() { let x = 1; let g = fn() { x }; x = 7; g() }; f()", 7},
+		// un let posterior crea otra variable: la closure conserva la suya
+		{"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() + x }; f()", 3},
+		{"let x = 0; if (true) { let y = 1; let inc = fn() { y += 1 }; inc(); x = y }; x", 2},
+	}
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{"len([1, 2, 3])", 3},
+		{"first([1, 2, 3])", 1},
+		{"last([1, 2, 3])", 3},
+		{"rest([1, 2, 3])", []int{2, 3}},
+		{"push([1, 2], 3)", []int{1, 2, 3}},
+		{`puts("hello")`, null{}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		kind     ErrorKind
+	}{
+		{"1 / 0", "division by zero", DivisionByZero},
+		{"1 % 0", "division by zero", DivisionByZero},
+		{"1 ~/ 0", "division by zero", DivisionByZero},
+		{"2 ** 63", "integer overflow: 2 ** 63", IntegerOverflow},
+		{"3 ** 100", "integer overflow: 3 ** 100", IntegerOverflow},
+		{"2 ** -1", "negative exponent: -1", InvalidOperand},
+		{"1 << -1", "negative shift count: -1", InvalidOperand},
+		{"1 ? 2 : 3", "non-boolean condition: INTEGER", TypeMismatch},
+		{`[1]?.a`, "invalid subscript data type for array access STRING", TypeMismatch},
+		{"let a = [null]; a?[0][1]", "index operator not supported: NULL", TypeMismatch},
+		{"let a = [1]; a[3] += 1", "index out of range", IndexOutOfRange},
+		{`let x = true; x++`, "unsupported types for binary operation: BOOLEAN INTEGER", TypeMismatch},
+		{"8 >> -2", "negative shift count: -2", InvalidOperand},
+		{"~true", "invalid type for this operation BOOLEAN", TypeMismatch},
+		{"true | false", "unsupported operator for binary operation BOOLEAN BOOLEAN", TypeMismatch},
+		{`"a" % "b"`, "unsupported operator for binary operation: STRING STRING", TypeMismatch},
+		{"true ** false", "unsupported operator for binary operation BOOLEAN BOOLEAN", TypeMismatch},
+		{`1 + "a"`, "unsupported types for binary operation: INTEGER STRING", TypeMismatch},
+		{`"a" - "b"`, "unsupported operator for binary operation: STRING STRING", TypeMismatch},
+		{"-true", "invalid type for this operation BOOLEAN", TypeMismatch},
+		{"[1, 2][5]", "index out of range", IndexOutOfRange},
+		{`[1, 2]["a"]`, "invalid subscript data type for array access STRING", TypeMismatch},
+		{"let f = fn(a) { a }; f()", "wrong number of arguments: want=1, got=0", WrongArity},
+		{"5()", "calling non-function and non-built-in", NotCallable},
+		{`len("a", "b")`, "wrong number of arguments for len: want=1, got=2", WrongArity},
+		{"if (1) { 2 }", "non-boolean condition: INTEGER", TypeMismatch},
+		{"1[0]", "index operator not supported: INTEGER", TypeMismatch},
+		{"true && 1", "unsupported types for binary operation: BOOLEAN INTEGER", TypeMismatch},
+		{`[1, 2]["a":]`, "invalid slice index data type STRING", TypeMismatch},
+		{`{"a": 1}[0:1]`, "slice operator not supported: HASH", TypeMismatch},
+		{"[1, 2][2] = 0", "index out of range", IndexOutOfRange},
+		{`[1, 2]["a"] = 0`, "invalid subscript data type for array access STRING", TypeMismatch},
+		{`{}[1] = 0`, "invalid subscript data type for array access INTEGER", TypeMismatch},
+		{`"abc"[0] = "x"`, "index assignment not supported: STRING", TypeMismatch},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER", TypeMismatch},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input, DefaultConfig())
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: want error %q, got=%v", tt.input, tt.expected, err)
+			continue
+		}
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Kind != tt.kind {
+			t.Errorf("%q: want RuntimeError of kind %s, got=%#v", tt.input, tt.kind, err)
+		}
+	}
+}
+
+// bytecode mal formado: debe fallar con un error, nunca con un pánico
+func TestMalformedBytecode(t *testing.T) {
+	tests := []struct {
+		instructions []code.Instruction
+		expected     string
+	}{
+		{[]code.Instruction{{OpCode: code.OpPop}}, "stack underflow"},
+		{[]code.Instruction{{OpCode: code.OpAdd}}, "stack underflow"},
+		{[]code.Instruction{{OpCode: code.OpConstant, Position: 3}}, "invalid constant index 3"},
+		{[]code.Instruction{{OpCode: code.OpFalse}, {OpCode: code.OpJumpNotTrue, Position: -4}}, "invalid jump target -4"},
+		{[]code.Instruction{{OpCode: code.OpGetLocal, Position: -1}}, "invalid local index -1"},
+		{[]code.Instruction{{OpCode: code.OpGetFree, Position: 0}}, "invalid free variable index 0"},
+		{[]code.Instruction{{OpCode: code.OpCall, Position: 2}}, "stack underflow"},
+		{[]code.Instruction{{OpCode: code.OpClosure, Position: 0}}, "invalid constant index 0"},
+		{[]code.Instruction{{OpCode: code.OpCode(250)}}, "unknown opcode 250"},
+	}
+
+	for _, tt := range tests {
+		machine := New(&compiler.ByteCode{Instructions: tt.instructions})
+		err := machine.Run()
+		if err == nil || err.Error() != tt.expected || !errors.Is(err, ErrInvalidBytecode) {
+			t.Errorf("%v: want invalid bytecode error %q, got=%v", tt.instructions, tt.expected, err)
+		}
+	}
+}
+
+func TestBuiltinPanicsAndNilResults(t *testing.T) {
+	registry := object.NewBuiltinRegistry()
+	registry.Register("explode", 0, func(ctx *object.ExecContext, args ...object.Object) object.Object {
+		panic("boom")
+	}, "")
+	registry.Register("nothing", 0, func(ctx *object.ExecContext, args ...object.Object) object.Object {
+		var integer *object.Integer
+		return integer
+	}, "")
+
+	compile := func(input string) *compiler.ByteCode {
+		comp := compiler.NewWithBuiltins(registry)
+		if err := comp.Compile(parse(t, input)); err != nil {
+			t.Fatalf("%q: compiler error: %s", input, err)
+		}
+		return comp.GetByteCode()
+	}
+
+	machine := NewWithBuiltins(compile("nothing()"), nil, registry)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "nothing()", null{}, machine.LastPoppedStackElem())
+
+	machine = NewWithBuiltins(compile("1 + explode()"), nil, registry)
+	err := machine.Run()
+	internal, ok := err.(*InternalError)
+	if !ok {
+		t.Fatalf("want *InternalError, got=%T (%v)", err, err)
+	}
+	if internal.Cause != "boom" || internal.OpCode != "CALL" || internal.IP != 2 {
+		t.Errorf("wrong internal error %+v", internal)
+	}
+
+	// también al invocar desde el anfitrión
+	explode, _ := registry.Lookup("explode")
+	builtin, _ := registry.Get(explode)
+	if _, err := New(compile("")).Call(builtin); err == nil {
+		t.Errorf("want an error calling explode")
+	} else if _, ok := err.(*InternalError); !ok {
+		t.Errorf("want *InternalError, got=%T", err)
+	}
+}
+
+// un array que se contiene a sí mismo se imprime sin recursión infinita
+func TestSelfReferencingCollections(t *testing.T) {
+	machine, err := run(t, `let a = [1, 2]; a[1] = a; let h = {"self": a}; h["h"] = h; [a, h["h"]]`, DefaultConfig())
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	expected := "[[1, [...]], {"
+	if inspect := machine.LastPoppedStackElem().Inspect(); !strings.HasPrefix(inspect, expected) || !strings.Contains(inspect, "h: {...}") {
+		t.Errorf("wrong inspect %s", inspect)
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	recursion := "let f = fn(n) { f(n + 1) }; f(0)"
+
+	var runtimeErr *RuntimeError
+	_, err := run(t, recursion, Config{MaxFrames: 16})
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != StackOverflow || !strings.Contains(err.Error(), "maximum call depth of 16") {
+		t.Errorf("want frame overflow, got=%v", err)
+	}
+
+	_, err = run(t, "[1, 2, 3, 4, 5, 6, 7, 8, 9]", Config{StackSize: 8})
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != StackOverflow {
+		t.Errorf("want stack overflow, got=%v", err)
+	}
+
+	_, err = run(t, "let a = 1; let b = 2; let c = 3", Config{MaxGlobals: 2})
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != LimitExceeded || err.Error() != "too many globals: limit is 2" {
+		t.Errorf("want the globals limit error, got=%v", err)
+	}
+
+	_, err = run(t, recursion, Config{InstructionBudget: 500})
+	if err != ErrBudgetExceeded {
+		t.Errorf("want ErrBudgetExceeded, got=%v", err)
+	}
+
+	_, err = run(t, `let f = fn(s) { f(s + s) }; f("ab")`, Config{MemoryLimit: 4096})
+	if _, ok := err.(*MemoryLimitError); !ok {
+		t.Errorf("want *MemoryLimitError, got=%v", err)
+	}
+
+	machine, err := run(t, "let a = 1; let b = 2; a + b", Config{})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if len(machine.Globals()) >= GLOBAL_SIZE {
+		t.Errorf("globals must grow on demand, got %d slots", len(machine.Globals()))
+	}
+}
+
+// first y last devuelven elementos ya guardados: no cuentan como reservas
+func TestBuiltinResultAllocations(t *testing.T) {
+	base, err := run(t, `let a = [[1, 2], "abc"];`, Config{})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	machine, err := run(t, `let a = [[1, 2], "abc"]; first(a); last(a); first(a)`, Config{})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if machine.Allocated() != base.Allocated() {
+		t.Errorf("first/last must not allocate: %d bytes, want %d", machine.Allocated(), base.Allocated())
+	}
+
+	machine, err = run(t, `let a = [[1, 2], "abc"]; push(a, 1)`, Config{})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if machine.Allocated() <= base.Allocated() {
+		t.Errorf("push must account the new array")
+	}
+}
+
+func TestRunContextCancellation(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, "while (true) { }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// cancelamos mientras el ciclo infinito se está ejecutando
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	err := New(comp.GetByteCode()).RunContext(ctx)
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) || !errors.Is(cancelled.Cause, context.Canceled) {
+		t.Errorf("want a CancelledError caused by context.Canceled, got=%v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = New(comp.GetByteCode()).RunContext(ctx)
+	if !errors.As(err, &cancelled) || !errors.Is(cancelled.Cause, context.DeadlineExceeded) {
+		t.Errorf("want a CancelledError caused by the deadline, got=%v", err)
+	}
+}
+
+func TestOutputStream(t *testing.T) {
+	var out bytes.Buffer
+	config := DefaultConfig()
+	config.Stdout = &out
+	config.Stdin = strings.NewReader("world\n")
+
+	_, err := run(t, `puts("hello"); print("a", 1); puts(readline())`, config)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if out.String() != "hello\na 1world\n" {
+		t.Errorf("wrong output %q", out.String())
+	}
+}
+
+// sin Stdin cada vm crea su buffer, así pueden correr en paralelo
+func TestDefaultStdinIsPerVM(t *testing.T) {
+	bytecode := compiler.New().GetByteCode()
+	a := New(bytecode)
+	b := New(bytecode)
+	if a.execContext.In == b.execContext.In {
+		t.Errorf("vms share the default stdin reader")
+	}
+
+	// con el mismo *bufio.Reader comparten la entrada
+	reader := bufio.NewReader(strings.NewReader("x\n"))
+	config := DefaultConfig()
+	config.Stdin = reader
+	if NewWithConfig(bytecode, nil, config).execContext.In != reader {
+		t.Errorf("the configured reader must be reused")
+	}
+}